@@ -3,15 +3,21 @@ package ast
 import (
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const indent = "    "
 
 var Unit = Expr{Node: TupleLit{}, Type: TupleSpec{}}
 
+// FuncLit is a function of one argument, e.g., `x -> x + 1`. The argument's
+// type may be annotated, e.g., `(x : int) -> x + 1`, in which case `ArgType`
+// is the annotation.
 type FuncLit struct {
-	Arg  Ident
-	Body Expr
+	Arg     Ident
+	ArgType Type // optional
+	Body    Expr
 }
 
 func (fl FuncLit) RenderGo(t Type) string {
@@ -19,7 +25,9 @@ func (fl FuncLit) RenderGo(t Type) string {
 }
 
 func (fl FuncLit) Equal(other FuncLit) bool {
-	return fl.Arg == other.Arg && fl.Body.Equal(other.Body)
+	return fl.Arg == other.Arg &&
+		optionalTypesEqual(fl.ArgType, other.ArgType) &&
+		fl.Body.Equal(other.Body)
 }
 
 func (fl FuncLit) EqualExprNode(other ExprNode) bool {
@@ -28,6 +36,10 @@ func (fl FuncLit) EqualExprNode(other ExprNode) bool {
 }
 
 func (fl FuncLit) String() string {
+	if fl.ArgType != nil {
+		return "(" + fl.Arg.String() + " : " + fl.ArgType.String() + ") -> " +
+			fl.Body.String()
+	}
 	return fl.Arg.String() + " -> " + fl.Body.String()
 }
 
@@ -107,6 +119,14 @@ func (i Ident) EqualExprNode(other ExprNode) bool {
 
 func (i Ident) String() string { return string(i) }
 
+// IsOperator returns true if the identifier names an infix operator (e.g.,
+// "+"). The parser desugars `a + b` into the call `(+ a) b`; such identifiers
+// can't otherwise be written in source.
+func (i Ident) IsOperator() bool {
+	r, _ := utf8.DecodeRuneInString(string(i))
+	return r != '_' && !unicode.IsLetter(r)
+}
+
 type Call struct {
 	Fn  Expr
 	Arg Expr
@@ -126,6 +146,12 @@ func (c Call) EqualExprNode(other ExprNode) bool {
 }
 
 func (c Call) String() string {
+	if fn, ok := c.Fn.Node.(Call); ok {
+		if op, ok := fn.Fn.Node.(Ident); ok && op.IsOperator() {
+			return "(" + fn.Arg.String() + " " + string(op) + " " +
+				c.Arg.String() + ")"
+		}
+	}
 	return c.Fn.String() + " " + c.Arg.String()
 }
 
@@ -168,6 +194,37 @@ func (b Block) String() string {
 	return "{ " + strings.Join(out, "; ") + " }"
 }
 
+// If is a conditional expression: `if Cond then Then else Else`.
+type If struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+}
+
+func (i If) RenderGo(t Type) string {
+	panic("If.RenderGo() not yet implemented")
+}
+
+func (i If) Visit(env ExprNodeVisitor) {
+	env.VisitIf(i)
+}
+
+func (i If) Equal(other If) bool {
+	return i.Cond.Equal(other.Cond) &&
+		i.Then.Equal(other.Then) &&
+		i.Else.Equal(other.Else)
+}
+
+func (i If) EqualExprNode(other ExprNode) bool {
+	otherIf, ok := other.(If)
+	return ok && i.Equal(otherIf)
+}
+
+func (i If) String() string {
+	return "if " + i.Cond.String() + " then " + i.Then.String() + " else " +
+		i.Else.String()
+}
+
 type ExprNodeVisitor interface {
 	VisitIntLit(IntLit)
 	VisitStringLit(StringLit)
@@ -176,6 +233,11 @@ type ExprNodeVisitor interface {
 	VisitBlock(Block)
 	VisitFuncLit(FuncLit)
 	VisitCall(Call)
+	VisitIf(If)
+	VisitMatch(Match)
+	VisitRecordLit(RecordLit)
+	VisitProject(Project)
+	VisitRecordUpdate(RecordUpdate)
 }
 
 type ExprNode interface {
@@ -212,6 +274,7 @@ func (c Call) Visit(env ExprNodeVisitor) {
 type Expr struct {
 	Type Type
 	Node ExprNode
+	Span Span
 }
 
 func (expr Expr) String() string { return expr.Node.String() }
@@ -220,6 +283,8 @@ func (expr Expr) RenderGo() string {
 	return expr.Node.RenderGo(expr.Type)
 }
 
+// Equal returns true if the expressions have equal types and nodes. Spans
+// are not compared.
 func (expr Expr) Equal(other Expr) bool {
 	if expr.Type != nil {
 		if !expr.Type.EqualType(other.Type) {
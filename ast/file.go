@@ -3,8 +3,11 @@ package ast
 type File struct {
 	Package string
 	Stmts   []Stmt
+	Span    Span
 }
 
+// Equal returns true if the files have the same package and equal statements.
+// Spans are not compared.
 func (f File) Equal(other File) bool {
 	if len(f.Stmts) != len(other.Stmts) {
 		return false
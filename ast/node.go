@@ -4,11 +4,23 @@ import "fmt"
 
 type LetDecl struct {
 	Ident   Ident
+	Type    Type // optional annotation, e.g., `let f : int -> int = ...`
 	Binding Expr
+	Span    Span
+
+	// Doc is the text of the decl's doc comment (the `///` lines preceding
+	// it), if any.
+	Doc string
 }
 
+// Equal returns true if the decls bind equal expressions to the same
+// identifier with the same annotation and have the same doc comment. Spans
+// are not compared.
 func (ld LetDecl) Equal(other LetDecl) bool {
-	return ld.Ident == other.Ident && ld.Binding.Equal(other.Binding)
+	return ld.Ident == other.Ident &&
+		optionalTypesEqual(ld.Type, other.Type) &&
+		ld.Binding.Equal(other.Binding) &&
+		ld.Doc == other.Doc
 }
 
 func (ld LetDecl) EqualDecl(other Decl) bool {
@@ -57,10 +69,22 @@ type Decl interface {
 }
 
 func (td TypeDecl) String() string {
-	panic("TypeDecl.String() not yet supported")
+	out := "type " + td.Name
+	for _, arg := range td.Args {
+		out += " " + string(arg)
+	}
+	if ss, ok := td.Type.(SumSpec); ok && len(ss) == 1 {
+		// a leading bar distinguishes a single variant from an alias
+		return out + " = | " + ss.String()
+	}
+	return out + " = " + td.Type.String()
 }
 
 func (ld LetDecl) String() string {
+	if ld.Type != nil {
+		return "let " + ld.Ident.String() + " : " + ld.Type.String() + " = " +
+			ld.Binding.String()
+	}
 	return "let " + ld.Ident.String() + " = " + ld.Binding.String()
 }
 
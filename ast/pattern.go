@@ -0,0 +1,207 @@
+package ast
+
+import "strings"
+
+// Pattern is the left-hand side of a match case. Like Expr, it pairs a node
+// with its type, which is the type of the values the pattern matches.
+type Pattern struct {
+	Type Type
+	Node PatternNode
+	Span Span
+}
+
+// Equal returns true if the patterns have equal types and nodes. Spans are
+// not compared.
+func (p Pattern) Equal(other Pattern) bool {
+	if p.Type != nil {
+		if !p.Type.EqualType(other.Type) {
+			return false
+		}
+	} else if other.Type != nil {
+		return false
+	}
+
+	if p.Node != nil {
+		return p.Node.EqualPatternNode(other.Node)
+	}
+	return other.Node == nil
+}
+
+func (p Pattern) String() string { return p.Node.String() }
+
+// Vars returns the variables bound by the pattern in the order they appear.
+func (p Pattern) Vars() []Ident {
+	switch x := p.Node.(type) {
+	case VarPattern:
+		return []Ident{Ident(x)}
+	case TuplePattern:
+		var out []Ident
+		for _, p := range x {
+			out = append(out, p.Vars()...)
+		}
+		return out
+	case CtorPattern:
+		if x.Arg.Node == nil {
+			return nil
+		}
+		return x.Arg.Vars()
+	default:
+		return nil
+	}
+}
+
+type PatternNode interface {
+	EqualPatternNode(PatternNode) bool
+	String() string
+}
+
+// WildcardPattern matches any value, e.g., `_`.
+type WildcardPattern struct{}
+
+func (wp WildcardPattern) EqualPatternNode(other PatternNode) bool {
+	_, ok := other.(WildcardPattern)
+	return ok
+}
+
+func (wp WildcardPattern) String() string { return "_" }
+
+// VarPattern matches any value and binds it to a variable, e.g., `x`.
+type VarPattern Ident
+
+func (vp VarPattern) EqualPatternNode(other PatternNode) bool {
+	otherVarPattern, ok := other.(VarPattern)
+	return ok && vp == otherVarPattern
+}
+
+func (vp VarPattern) String() string { return string(vp) }
+
+// LitPattern matches values equal to an int or string literal, e.g., `1`.
+type LitPattern struct {
+	Lit ExprNode
+}
+
+func (lp LitPattern) EqualPatternNode(other PatternNode) bool {
+	otherLitPattern, ok := other.(LitPattern)
+	return ok && lp.Lit.EqualExprNode(otherLitPattern.Lit)
+}
+
+func (lp LitPattern) String() string { return lp.Lit.String() }
+
+// TuplePattern matches tuples whose elements match the respective patterns,
+// e.g., `(x, _)`.
+type TuplePattern []Pattern
+
+func (tp TuplePattern) Equal(other TuplePattern) bool {
+	if len(tp) != len(other) {
+		return false
+	}
+	for i, p := range tp {
+		if !p.Equal(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (tp TuplePattern) EqualPatternNode(other PatternNode) bool {
+	otherTuplePattern, ok := other.(TuplePattern)
+	return ok && tp.Equal(otherTuplePattern)
+}
+
+func (tp TuplePattern) String() string {
+	elts := make([]string, len(tp))
+	for i, p := range tp {
+		elts[i] = p.String()
+	}
+	return "(" + strings.Join(elts, ", ") + ")"
+}
+
+// CtorPattern matches the values of a sum type which were made with the
+// constructor `Ctor` and whose argument matches `Arg`, e.g., `Circle r`. The
+// argument's node is nil if the constructor doesn't take an argument. Like a
+// Call's Fn, `Ctor` is an Ident expression typed with the constructor's type.
+type CtorPattern struct {
+	Ctor Expr
+	Arg  Pattern
+}
+
+// Name returns the name of the pattern's constructor.
+func (cp CtorPattern) Name() string { return string(cp.Ctor.Node.(Ident)) }
+
+func (cp CtorPattern) Equal(other CtorPattern) bool {
+	return cp.Ctor.Equal(other.Ctor) && cp.Arg.Equal(other.Arg)
+}
+
+func (cp CtorPattern) EqualPatternNode(other PatternNode) bool {
+	otherCtorPattern, ok := other.(CtorPattern)
+	return ok && cp.Equal(otherCtorPattern)
+}
+
+func (cp CtorPattern) String() string {
+	switch cp.Arg.Node.(type) {
+	case nil:
+		return cp.Name()
+	case CtorPattern:
+		if cp.Arg.Node.(CtorPattern).Arg.Node != nil {
+			return cp.Name() + " (" + cp.Arg.String() + ")"
+		}
+	}
+	return cp.Name() + " " + cp.Arg.String()
+}
+
+// Case is one of the cases of a match expression: if the matched value
+// matches `Pattern`, the match evaluates to `Body`.
+type Case struct {
+	Pattern Pattern
+	Body    Expr
+	Span    Span
+}
+
+func (c Case) Equal(other Case) bool {
+	return c.Pattern.Equal(other.Pattern) && c.Body.Equal(other.Body)
+}
+
+func (c Case) String() string {
+	return c.Pattern.String() + " -> " + c.Body.String()
+}
+
+// Match evaluates to the body of the first case whose pattern matches `Expr`,
+// e.g., `match s { Circle r -> r; Rect (w, h) -> w }`.
+type Match struct {
+	Expr  Expr
+	Cases []Case
+}
+
+func (m Match) RenderGo(t Type) string {
+	panic("Match.RenderGo() not yet implemented")
+}
+
+func (m Match) Visit(env ExprNodeVisitor) {
+	env.VisitMatch(m)
+}
+
+func (m Match) Equal(other Match) bool {
+	if !m.Expr.Equal(other.Expr) || len(m.Cases) != len(other.Cases) {
+		return false
+	}
+	for i, c := range m.Cases {
+		if !c.Equal(other.Cases[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Match) EqualExprNode(other ExprNode) bool {
+	otherMatch, ok := other.(Match)
+	return ok && m.Equal(otherMatch)
+}
+
+func (m Match) String() string {
+	cases := make([]string, len(m.Cases))
+	for i, c := range m.Cases {
+		cases[i] = c.String()
+	}
+	return "match " + m.Expr.String() + " { " + strings.Join(cases, "; ") +
+		" }"
+}
@@ -0,0 +1,265 @@
+package ast
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Field is a named field of a record type.
+type Field struct {
+	Name string
+	Type Type
+}
+
+func (f Field) Equal(other Field) bool {
+	return f.Name == other.Name && f.Type.EqualType(other.Type)
+}
+
+func (f Field) String() string { return f.Name + ": " + f.Type.String() }
+
+// RecordSpec is a record type, e.g., `{name: string, age: int}`. Record types
+// are structural: two record types are the same type if they have the same
+// fields. The fields are sorted by name (see NewRecordSpec) so the order in
+// which they're written doesn't matter.
+//
+// A record type whose `Rest` is a row variable is open: it's the type of any
+// record with at least its fields, e.g., `{name: string | r}`. The row
+// variable stands for the remaining fields, so it's bound to a (possibly
+// open) record type when the type is unified with one which has more fields.
+// A record type without a row variable is closed.
+type RecordSpec struct {
+	Fields []Field
+	Rest   TypeVar
+}
+
+// NewRecordSpec returns a closed record type with `fields` sorted by name.
+func NewRecordSpec(fields []Field) RecordSpec {
+	return RecordSpec{Fields: sortFields(fields)}
+}
+
+func sortFields(fields []Field) []Field {
+	out := make([]Field, len(fields))
+	copy(out, fields)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Field returns the type of the field named `name`.
+func (rs RecordSpec) Field(name string) (Type, bool) {
+	for _, f := range rs.Fields {
+		if f.Name == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// IsOpen returns true if the record type has a row variable.
+func (rs RecordSpec) IsOpen() bool { return rs.Rest != "" }
+
+// WithRest returns the record type with its row variable replaced by `rest`,
+// which must be a row variable or a record type whose fields the record type
+// doesn't have. In the latter case, the result has the fields of both and
+// the row variable of `rest`.
+func (rs RecordSpec) WithRest(rest Type) RecordSpec {
+	switch x := rest.(type) {
+	case TypeVar:
+		return RecordSpec{Fields: rs.Fields, Rest: x}
+	case RecordSpec:
+		fields := append(append([]Field(nil), rs.Fields...), x.Fields...)
+		return RecordSpec{Fields: sortFields(fields), Rest: x.Rest}
+	default:
+		panic("Row variable bound to a non-record type: " + rest.String())
+	}
+}
+
+func (rs RecordSpec) Equal(other RecordSpec) bool {
+	if rs.Rest != other.Rest || len(rs.Fields) != len(other.Fields) {
+		return false
+	}
+	for i, f := range rs.Fields {
+		if !f.Equal(other.Fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rs RecordSpec) EqualType(other Type) bool {
+	otherRecordSpec, ok := other.(RecordSpec)
+	return ok && rs.Equal(otherRecordSpec)
+}
+
+func (rs RecordSpec) Replace(types map[TypeVar]Type) Type {
+	fields := make([]Field, len(rs.Fields))
+	for i, f := range rs.Fields {
+		fields[i] = Field{Name: f.Name, Type: f.Type.Replace(types)}
+	}
+	out := RecordSpec{Fields: fields, Rest: rs.Rest}
+	if rest, found := types[rs.Rest]; found && rs.IsOpen() {
+		return out.WithRest(rest)
+	}
+	return out
+}
+
+func (rs RecordSpec) RenderGo() string {
+	if rs.IsOpen() {
+		panic("RecordSpec.RenderGo() not supported for open records")
+	}
+	fields := make([]string, len(rs.Fields))
+	for i, f := range rs.Fields {
+		fields[i] = ExportedName(f.Name) + " " + f.Type.RenderGo()
+	}
+	return "struct {" + strings.Join(fields, "; ") + "}"
+}
+
+func (rs RecordSpec) RenderGoIdent() string { return rs.RenderGo() }
+
+func (rs RecordSpec) RenderGoLit(tr TypeRef) string { return rs.RenderGo() }
+
+func (rs RecordSpec) Visit(tv TypeVisitor) {
+	tv.VisitRecordSpec(rs)
+}
+
+func (rs RecordSpec) String() string {
+	fields := make([]string, len(rs.Fields))
+	for i, f := range rs.Fields {
+		fields[i] = f.String()
+	}
+	if !rs.IsOpen() {
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	if len(fields) < 1 {
+		return "{| " + rs.Rest.String() + "}"
+	}
+	return "{" + strings.Join(fields, ", ") + " | " + rs.Rest.String() + "}"
+}
+
+// ExportedName returns the name of the Go struct field for the record field
+// `name`, which is `name` with its first letter capitalized so the field is
+// exported.
+func ExportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// FieldValue is a named field of a record literal or record update.
+type FieldValue struct {
+	Name  string
+	Value Expr
+}
+
+func (fv FieldValue) Equal(other FieldValue) bool {
+	return fv.Name == other.Name && fv.Value.Equal(other.Value)
+}
+
+func (fv FieldValue) String() string {
+	return fv.Name + " = " + fv.Value.String()
+}
+
+func fieldValuesEqual(fvs, other []FieldValue) bool {
+	if len(fvs) != len(other) {
+		return false
+	}
+	for i, fv := range fvs {
+		if !fv.Equal(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldValuesString(fvs []FieldValue) string {
+	fields := make([]string, len(fvs))
+	for i, fv := range fvs {
+		fields[i] = fv.String()
+	}
+	return strings.Join(fields, ", ")
+}
+
+// RecordLit is a record literal, e.g., `{name = "Alice", age = 3}`. The
+// fields are kept in the order in which they're written since that's the
+// order in which they're evaluated.
+type RecordLit []FieldValue
+
+func (rl RecordLit) RenderGo(t Type) string {
+	fields := make([]string, len(rl))
+	for i, fv := range rl {
+		fields[i] = ExportedName(fv.Name) + ": " + fv.Value.RenderGo()
+	}
+	return t.RenderGo() + "{" + strings.Join(fields, ", ") + "}"
+}
+
+func (rl RecordLit) Visit(env ExprNodeVisitor) {
+	env.VisitRecordLit(rl)
+}
+
+func (rl RecordLit) EqualExprNode(other ExprNode) bool {
+	otherRecordLit, ok := other.(RecordLit)
+	return ok && fieldValuesEqual(rl, otherRecordLit)
+}
+
+func (rl RecordLit) String() string {
+	return "{" + fieldValuesString(rl) + "}"
+}
+
+// Project is the value of the field `Field` of `Record`, e.g., `p.name`.
+type Project struct {
+	Record Expr
+	Field  string
+}
+
+func (p Project) RenderGo(t Type) string {
+	return p.Record.RenderGo() + "." + ExportedName(p.Field)
+}
+
+func (p Project) Visit(env ExprNodeVisitor) {
+	env.VisitProject(p)
+}
+
+func (p Project) EqualExprNode(other ExprNode) bool {
+	otherProject, ok := other.(Project)
+	return ok && p.Record.Equal(otherProject.Record) &&
+		p.Field == otherProject.Field
+}
+
+func (p Project) String() string {
+	switch p.Record.Node.(type) {
+	case Call, If, Match, FuncLit:
+		return "(" + p.Record.String() + ")." + p.Field
+	}
+	return p.Record.String() + "." + p.Field
+}
+
+// RecordUpdate is a copy of `Record` with the values of `Fields` in place of
+// the existing values of those fields, e.g., `{p | age = 4}`.
+type RecordUpdate struct {
+	Record Expr
+	Fields []FieldValue
+}
+
+func (ru RecordUpdate) RenderGo(t Type) string {
+	panic("RecordUpdate.RenderGo() not yet implemented")
+}
+
+func (ru RecordUpdate) Visit(env ExprNodeVisitor) {
+	env.VisitRecordUpdate(ru)
+}
+
+func (ru RecordUpdate) EqualExprNode(other ExprNode) bool {
+	otherRecordUpdate, ok := other.(RecordUpdate)
+	return ok && ru.Record.Equal(otherRecordUpdate.Record) &&
+		fieldValuesEqual(ru.Fields, otherRecordUpdate.Fields)
+}
+
+func (ru RecordUpdate) String() string {
+	return "{" + ru.Record.String() + " | " + fieldValuesString(ru.Fields) +
+		"}"
+}
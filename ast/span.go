@@ -0,0 +1,36 @@
+package ast
+
+import "strconv"
+
+// Position is a location in a source file.
+type Position struct {
+	// Offset is the byte offset from the start of the file, starting at 0.
+	Offset int
+
+	// Line is the line number, starting at 1.
+	Line int
+
+	// Column is the rune offset from the start of the line, starting at 1.
+	Column int
+}
+
+// IsValid returns true if the position was set by the parser.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position in the form "line:column".
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Span is the region of a source file from which a node was parsed. `End` is
+// the position immediately after the node.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsValid returns true if the span was set by the parser.
+func (s Span) IsValid() bool { return s.Start.IsValid() }
+
+// String returns the span's start position in the form "line:column".
+func (s Span) String() string { return s.Start.String() }
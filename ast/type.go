@@ -24,6 +24,14 @@ func (p Primitive) String() string {
 	return string(p)
 }
 
+// optionalTypesEqual returns true if both types are nil or if they're equal.
+func optionalTypesEqual(t, other Type) bool {
+	if t != nil {
+		return t.EqualType(other)
+	}
+	return other == nil
+}
+
 type ArgSpec struct {
 	Name string
 	Type Type // optional
@@ -153,6 +161,11 @@ func (fs FuncSpec) RenderGoIdent() string {
 func (fs FuncSpec) RenderGoLit(tr TypeRef) string { return fs.RenderGo() }
 
 func (fs FuncSpec) String() string {
+	// the arrow is right-associative, so only a function argument needs
+	// parentheses
+	if arg, ok := fs.Arg.(FuncSpec); ok {
+		return "(" + arg.String() + ") -> " + fs.Ret.String()
+	}
 	return fs.Arg.String() + " -> " + fs.Ret.String()
 }
 
@@ -160,29 +173,28 @@ const beta = "ß"
 const pi = "π"
 const omega = "Ω"
 
+// TypeRef refers to a declared type by name, applied to its arguments (if
+// any), e.g., `Option int`. Decl is the referenced declaration, if it's
+// known.
 type TypeRef struct {
 	Name string
 	Decl *TypeDecl
-	Arg  Type
+	Args []Type
 }
 
+// Equal returns true if the refs have the same name and equal arguments. The
+// decls aren't compared since they're determined by the name (and the decl of
+// a recursive type refers to itself).
 func (tr TypeRef) Equal(other TypeRef) bool {
-	if tr.Name != other.Name {
+	if tr.Name != other.Name || len(tr.Args) != len(other.Args) {
 		return false
 	}
-	if tr.Arg != nil {
-		if !tr.Arg.EqualType(other.Arg) {
+	for i, arg := range tr.Args {
+		if !arg.EqualType(other.Args[i]) {
 			return false
 		}
-	} else {
-		if other.Arg != nil {
-			return false
-		}
-	}
-	if tr.Decl != nil && other.Decl != nil {
-		return tr.Decl.Equal(*other.Decl)
 	}
-	return tr.Decl == other.Decl
+	return true
 }
 
 func (tr TypeRef) EqualType(other Type) bool {
@@ -191,30 +203,151 @@ func (tr TypeRef) EqualType(other Type) bool {
 }
 
 func (tr TypeRef) Replace(types map[TypeVar]Type) Type {
-	return TypeRef{Decl: tr.Decl, Arg: tr.Arg.Replace(types)}
+	if len(tr.Args) < 1 {
+		return tr
+	}
+	args := make([]Type, len(tr.Args))
+	for i, arg := range tr.Args {
+		args[i] = arg.Replace(types)
+	}
+	return TypeRef{Name: tr.Name, Decl: tr.Decl, Args: args}
 }
 
 func (tr TypeRef) RenderGo() string {
 	return tr.RenderGoLit(tr)
 }
 
+// RenderGoLit renders the ref as the Go type of its declaration. A ref which
+// hasn't been resolved against its declaration is rendered as its Go
+// identifier.
 func (tr TypeRef) RenderGoLit(TypeRef) string {
+	if tr.Decl == nil {
+		return tr.RenderGoIdent()
+	}
 	types := map[TypeVar]Type{}
-	tr2 := tr
-	for _, v := range tr.Decl.Args {
-		types[v] = tr2.Arg
-		if tr, ok := tr2.Arg.(TypeRef); ok {
-			tr2 = tr
+	for i, v := range tr.Decl.Args {
+		if i < len(tr.Args) {
+			types[v] = tr.Args[i]
 		}
 	}
 	return tr.Decl.Type.Replace(types).RenderGoLit(tr)
 }
 
+// Variants returns the variants of the sum type which the ref refers to, with
+// the ref's arguments substituted for the declaration's type parameters. It
+// returns nil if the ref doesn't refer to a sum type.
+func (tr TypeRef) Variants() SumSpec {
+	if tr.Decl == nil {
+		return nil
+	}
+	ss, ok := tr.Decl.Type.(SumSpec)
+	if !ok {
+		return nil
+	}
+	types := map[TypeVar]Type{}
+	for i, v := range tr.Decl.Args {
+		if i < len(tr.Args) {
+			types[v] = tr.Args[i]
+		}
+	}
+	return ss.Replace(types).(SumSpec)
+}
+
 func (tr TypeRef) String() string {
-	if tr.Arg == nil {
-		return tr.Name
+	out := tr.Name
+	for _, arg := range tr.Args {
+		switch x := arg.(type) {
+		case FuncSpec:
+			out += " (" + x.String() + ")"
+		case TypeRef:
+			if len(x.Args) > 0 {
+				out += " (" + x.String() + ")"
+				continue
+			}
+			out += " " + x.String()
+		default:
+			out += " " + x.String()
+		}
+	}
+	return out
+}
+
+// Variant is one of the alternatives of a sum type: the name of its
+// constructor and the type of the constructor's argument, if it takes one.
+type Variant struct {
+	Name string
+	Type Type // optional
+}
+
+func (v Variant) Equal(other Variant) bool {
+	if v.Type != nil {
+		return v.Name == other.Name && v.Type.EqualType(other.Type)
+	}
+	return v.Name == other.Name && other.Type == nil
+}
+
+func (v Variant) String() string {
+	if v.Type == nil {
+		return v.Name
+	}
+	return v.Name + " " + v.Type.String()
+}
+
+// SumSpec is a sum type, e.g., `Circle int | Rect (int, int)`. Sum types are
+// only written in type declarations; elsewhere they're referred to by the
+// name of their declaration.
+type SumSpec []Variant
+
+func (ss SumSpec) Equal(other SumSpec) bool {
+	if len(ss) != len(other) {
+		return false
+	}
+	for i, v := range ss {
+		if !v.Equal(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Variant returns the variant whose constructor is named `name`.
+func (ss SumSpec) Variant(name string) (Variant, bool) {
+	for _, v := range ss {
+		if v.Name == name {
+			return v, true
+		}
 	}
-	return tr.Name + " " + tr.Arg.String()
+	return Variant{}, false
+}
+
+func (ss SumSpec) EqualType(other Type) bool {
+	otherSumSpec, ok := other.(SumSpec)
+	return ok && ss.Equal(otherSumSpec)
+}
+
+func (ss SumSpec) Replace(types map[TypeVar]Type) Type {
+	ss2 := make(SumSpec, len(ss))
+	for i, v := range ss {
+		ss2[i] = v
+		if v.Type != nil {
+			ss2[i].Type = v.Type.Replace(types)
+		}
+	}
+	return ss2
+}
+
+func (ss SumSpec) RenderGo() string { panic("SumSpec.RenderGo()") }
+
+func (ss SumSpec) RenderGoIdent() string { panic("SumSpec.RenderGoIdent()") }
+
+func (ss SumSpec) RenderGoLit(tr TypeRef) string { return tr.Name }
+
+func (ss SumSpec) String() string {
+	variants := make([]string, len(ss))
+	for i, v := range ss {
+		variants[i] = v.String()
+	}
+	return strings.Join(variants, " | ")
 }
 
 func (p Primitive) Visit(tv TypeVisitor) {
@@ -232,6 +365,9 @@ func (tr TypeRef) Visit(tv TypeVisitor) {
 func (tv TypeVar) Visit(tvis TypeVisitor) {
 	tvis.VisitTypeVar(tv)
 }
+func (ss SumSpec) Visit(tv TypeVisitor) {
+	tv.VisitSumSpec(ss)
+}
 
 type TypeVisitor interface {
 	VisitPrimitive(p Primitive)
@@ -239,20 +375,28 @@ type TypeVisitor interface {
 	VisitTupleSpec(ts TupleSpec)
 	VisitTypeRef(tr TypeRef)
 	VisitTypeVar(tv TypeVar)
+	VisitSumSpec(ss SumSpec)
+	VisitRecordSpec(rs RecordSpec)
 }
 
 func (tr TypeRef) RenderGoIdent() string {
-	if tr.Arg == nil {
-		return tr.Decl.Name
+	out := tr.Name
+	for _, arg := range tr.Args {
+		// TODO: This is likely incorrect
+		out += beta + arg.RenderGoIdent()
 	}
-	// TODO: This is likely incorrect
-	return tr.Decl.Name + beta + tr.Arg.RenderGoIdent()
+	return out
 }
 
 type TypeDecl struct {
 	Name string
 	Type Type
 	Args []TypeVar
+	Span Span
+
+	// Doc is the text of the decl's doc comment (the `///` lines preceding
+	// it), if any.
+	Doc string
 }
 
 func (td TypeDecl) EqualDecl(other Decl) bool {
@@ -270,8 +414,11 @@ func (td TypeDecl) EqualStmt(other Stmt) bool {
 	return ok && td.Equal(otherTypeDecl)
 }
 
+// Equal returns true if the decls have the same name, arguments, type, and
+// doc comment. Spans are not compared.
 func (td TypeDecl) Equal(other TypeDecl) bool {
 	if td.Name != other.Name ||
+		td.Doc != other.Doc ||
 		!td.Type.EqualType(other.Type) ||
 		len(td.Args) != len(other.Args) {
 		return false
@@ -0,0 +1,70 @@
+package codegen
+
+import (
+	"github.com/dave/jennifer/jen"
+	"github.com/weberc2/gallium/ast"
+)
+
+// body returns statements which return the value of `expr`, for the body of a
+// Go function (or a branch of one). Blocks and conditionals are hoisted into
+// the statements rather than rendered as immediately-invoked functions, so
+// `x -> { let y = x * 2; if y > 10 then y else 0 }` is rendered as:
+//
+//	func(x int) int {
+//		y := (x * 2)
+//		_ = y
+//		if y > 10 {
+//			return y
+//		}
+//		return 0
+//	}
+//
+// `declared` holds the identifiers which are already declared in the Go scope
+// into which the statements are hoisted (e.g., the function's parameters).
+// Go doesn't allow a variable to be redeclared in the same scope, so a let
+// which shadows one of them (or an earlier let of the same block) starts a
+// nested scope which holds the rest of the block.
+func (g generator) body(
+	expr ast.Expr,
+	declared []ast.Ident,
+) []jen.Code {
+	switch x := expr.Node.(type) {
+	case ast.Block:
+		scope := append([]ast.Ident(nil), declared...)
+		var stmts []jen.Code
+		for i, stmt := range x.Stmts {
+			switch s := stmt.(type) {
+			case ast.LetDecl:
+				if containsIdent(scope, s.Ident) {
+					rest := ast.Expr{
+						Type: expr.Type,
+						Node: ast.Block{Stmts: x.Stmts[i:], Expr: x.Expr},
+						Span: expr.Span,
+					}
+					return append(stmts, jen.Block(g.body(rest, nil)...))
+				}
+				// the rest of the block needn't use the variable, but Go
+				// requires that it's used
+				stmts = append(
+					stmts,
+					jen.Id(string(s.Ident)).Op(":=").Add(g.expr(s.Binding)),
+					jen.Id("_").Op("=").Id(string(s.Ident)),
+				)
+				scope = append(scope, s.Ident)
+				g = g.shadow(s.Ident)
+			case ast.Expr:
+				stmts = append(stmts, jen.Id("_").Op("=").Add(g.expr(s)))
+			}
+		}
+		return append(stmts, g.body(x.Expr, scope)...)
+	case ast.If:
+		return append(
+			[]jen.Code{
+				jen.If(g.expr(x.Cond)).Block(g.body(x.Then, nil)...),
+			},
+			g.body(x.Else, declared)...,
+		)
+	default:
+		return []jen.Code{jen.Return(g.expr(expr))}
+	}
+}
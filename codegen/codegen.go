@@ -3,6 +3,7 @@ package codegen
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/dave/jennifer/jen"
 	"github.com/weberc2/gallium/ast"
@@ -16,6 +17,8 @@ func Type(t ast.Type) *jen.Statement {
 			return jen.Int()
 		case "string":
 			return jen.String()
+		case "bool":
+			return jen.Bool()
 		default:
 			panic("codegen not supported for primitive:" + string(x))
 		}
@@ -25,34 +28,101 @@ func Type(t ast.Type) *jen.Statement {
 			types[i] = jen.Id("_" + strconv.Itoa(i)).Add(Type(t))
 		}
 		return jen.Struct(types...)
+	case ast.FuncSpec:
+		// curried functions are uncurried (see signature)
+		args, ret := signature(x)
+		params := make([]jen.Code, len(args))
+		for i, arg := range args {
+			params[i] = Type(arg)
+		}
+		return jen.Func().Params(params...).Add(Type(ret))
 	case ast.TypeVar:
-		panic("codegen not supported for generic types")
+		// the type parameter of a generic function (see generator) or of a
+		// sum type, which starts with an underscore so it can't clash with
+		// the names of the file's types and bindings (see parser.Ident)
+		return jen.Id("_" + ast.ExportedName(string(x)))
+	case ast.RecordSpec:
+		if x.IsOpen() {
+			panic("codegen not supported for open record types")
+		}
+		fields := make([]jen.Code, len(x.Fields))
+		for i, f := range x.Fields {
+			fields[i] = jen.Id(ast.ExportedName(f.Name)).Add(Type(f.Type))
+		}
+		return jen.Struct(fields...)
+	case ast.TypeRef:
+		return generic(x.Name, x.Args)
 	default:
 		panic(fmt.Sprintf("codegen not supported for %T", t))
 	}
 }
 
-func Expr(expr ast.Expr) *jen.Statement {
+// Expr renders `expr`, which mustn't refer to polymorphic top-level functions
+// (see File).
+func Expr(expr ast.Expr) *jen.Statement { return generator{}.expr(expr) }
+
+func (g generator) expr(expr ast.Expr) *jen.Statement {
 	switch x := expr.Node.(type) {
 	case ast.IntLit:
 		return jen.Lit(int(x))
 	case ast.StringLit:
 		return jen.Lit(string(x))
 	case ast.Ident:
+		if v, ok := constructor(x, expr.Type); ok && v.Type == nil {
+			return construct(expr.Type, v, nil)
+		}
+		// operators and constructors aren't Go functions, so they're
+		// wrapped in closures
+		if _, ok := constructor(x, expr.Type); ok || x.IsOperator() {
+			return g.apply(expr, nil, expr.Type)
+		}
+		if _, ok := g.generics[x]; ok {
+			return g.instantiate(x, expr.Type)
+		}
 		return jen.Id(string(x))
 	case ast.TupleLit:
 		fields := make([]jen.Code, len(x))
 		for i, expr := range x {
-			fields[i] = jen.Id("_" + strconv.Itoa(i)).Op(":").Add(Expr(expr))
+			fields[i] = jen.Id("_" + strconv.Itoa(i)).Op(":").Add(g.expr(expr))
 		}
 		return jen.Add(Type(expr.Type)).Values(fields...)
 	case ast.FuncLit:
-		fs := expr.Type.(ast.FuncSpec)
-		return jen.Func().Params(
-			jen.Id(string(x.Arg)).Add(Type(fs.Arg)),
-		).Add(Type(fs.Ret)).Add(jen.Block(jen.Return(Expr(x.Body))))
+		params, ret, stmts := g.function(expr)
+		return jen.Func().Params(params...).Add(Type(ret)).Block(stmts...)
 	case ast.Call:
-		return jen.Add(Expr(x.Fn)).Call(Expr(x.Arg))
+		fn, args := uncurry(expr)
+		return g.apply(fn, args, expr.Type)
+	case ast.Block, ast.If:
+		// render as an immediately-invoked function into which the block or
+		// conditional is hoisted
+		return jen.Func().Params().Add(Type(expr.Type)).Block(
+			g.body(expr, nil)...,
+		).Call()
+	case ast.Match:
+		return g.match(expr, x)
+	case ast.RecordLit:
+		fields := make([]jen.Code, len(x))
+		for i, f := range x {
+			fields[i] = jen.Id(ast.ExportedName(f.Name)).Op(":").Add(
+				g.expr(f.Value),
+			)
+		}
+		return jen.Add(Type(expr.Type)).Values(fields...)
+	case ast.Project:
+		return jen.Add(g.expr(x.Record)).Dot(ast.ExportedName(x.Field))
+	case ast.RecordUpdate:
+		// update a copy of the record in an immediately-invoked function
+		stmts := []jen.Code{jen.Id("_r").Op(":=").Add(g.expr(x.Record))}
+		for _, f := range x.Fields {
+			stmts = append(
+				stmts,
+				jen.Id("_r").Dot(ast.ExportedName(f.Name)).Op("=").Add(
+					g.expr(f.Value),
+				),
+			)
+		}
+		stmts = append(stmts, jen.Return(jen.Id("_r")))
+		return jen.Func().Params().Add(Type(expr.Type)).Block(stmts...).Call()
 	default:
 		panic(fmt.Sprintf(
 			"Expr() not yet implemented for %T",
@@ -61,19 +131,166 @@ func Expr(expr ast.Expr) *jen.Statement {
 	}
 }
 
-func Stmt(stmt ast.Stmt) *jen.Statement {
+// generic renders the type `name` instantiated with the type arguments
+// `args`, e.g., `Option[int]`. A type without arguments is rendered as just
+// its name.
+func generic(name string, args []ast.Type) *jen.Statement {
+	if len(args) < 1 {
+		return jen.Id(name)
+	}
+	types := make([]jen.Code, len(args))
+	for i, arg := range args {
+		types[i] = Type(arg)
+	}
+	return jen.Id(name).Types(types...)
+}
+
+// variant renders the struct type of the variant `name` of the sum type `t`.
+// A variant takes the sum type's arguments, e.g., `Some[int]` is the variant
+// `Some` of `Option int`.
+func variant(t ast.Type, name string) *jen.Statement {
+	return generic(name, t.(ast.TypeRef).Args)
+}
+
+// constructor returns the variant constructed by `ident` if it's the
+// constructor of a sum type, given its type `t`.
+func constructor(ident ast.Ident, t ast.Type) (ast.Variant, bool) {
+	fs, isFunc := t.(ast.FuncSpec)
+	if isFunc {
+		t = fs.Ret
+	}
+	ref, ok := t.(ast.TypeRef)
+	if !ok {
+		return ast.Variant{}, false
+	}
+	v, ok := ref.Variants().Variant(string(ident))
+	return v, ok && (v.Type != nil) == isFunc
+}
+
+// construct returns a value of the sum type `t` made with the variant `v`.
+// The value is converted to the sum type's interface so Go infers the sum
+// type rather than the variant's struct type.
+func construct(t ast.Type, v ast.Variant, arg jen.Code) *jen.Statement {
+	var fields []jen.Code
+	if arg != nil {
+		fields = append(fields, jen.Id("_0").Op(":").Add(arg))
+	}
+	return jen.Add(Type(t)).Call(variant(t, v.Name).Values(fields...))
+}
+
+// sumType renders the sum type `decl` as a sealed interface (one with an
+// unexported marker method) which is implemented by a struct for each of the
+// variants. A variant's argument, if it takes one, is the struct's `_0`
+// field. A parameterized sum type and its variants are rendered as generic
+// types with the same type parameters, e.g., `type Option a = Some a | None;`
+// is rendered as `type Option[_A any] interface { isOption() }`, `type
+// Some[_A any] struct { _0 _A }` and `type None[_A any] struct{}`.
+func sumType(decl ast.TypeDecl) *jen.Statement {
+	params := make([]jen.Code, len(decl.Args))
+	args := make([]ast.Type, len(decl.Args))
+	for i, tv := range decl.Args {
+		params[i] = Type(tv).Any()
+		args[i] = tv
+	}
+	declare := func(name string) *jen.Statement {
+		if len(params) < 1 {
+			return jen.Type().Id(name)
+		}
+		return jen.Type().Id(name).Types(params...)
+	}
+
+	marker := "is" + decl.Name
+	out := declare(decl.Name).Interface(jen.Id(marker).Params())
+	for _, v := range decl.Type.(ast.SumSpec) {
+		var fields []jen.Code
+		if v.Type != nil {
+			fields = append(fields, jen.Id("_0").Add(Type(v.Type)))
+		}
+		out.Line().Line().Add(declare(v.Name)).Struct(fields...)
+		out.Line().Line().Func().Params(generic(v.Name, args)).Id(marker).
+			Params().Block()
+	}
+	return out
+}
+
+// funcDecl renders the top-level function `ident` as a Go func declaration
+// (see function), e.g., `let add = x -> y -> x + y;` is rendered as
+// `func add(x int, y int) int { return (x + y) }`. A polymorphic function is
+// rendered as a generic function (see typeParams).
+func (g generator) funcDecl(
+	ident ast.Ident,
+	binding ast.Expr,
+) *jen.Statement {
+	params, ret, stmts := g.function(binding)
+	out := jen.Func().Id(string(ident))
+	if types := g.typeParams(ident, binding.Type); len(types) > 0 {
+		out.Types(types...)
+	}
+	return out.Params(params...).Add(Type(ret)).Block(stmts...)
+}
+
+// isFuncDecl returns true if `letDecl` is rendered as a Go func declaration,
+// i.e., if it's bound to a function literal. `main` is rendered as Go's
+// `main` function regardless.
+func isFuncDecl(letDecl ast.LetDecl) bool {
+	_, ok := letDecl.Binding.Node.(ast.FuncLit)
+	return ok && letDecl.Ident != "main"
+}
+
+// Stmt renders `stmt`, which mustn't refer to polymorphic top-level
+// functions (see File).
+func Stmt(stmt ast.Stmt) *jen.Statement { return generator{}.stmt(stmt) }
+
+func (g generator) stmt(stmt ast.Stmt) *jen.Statement {
 	switch x := stmt.(type) {
 	case ast.LetDecl:
-		return jen.Var().Id(string(x.Ident)).Op("=").Add(Expr(x.Binding))
+		if x.Ident == "main" {
+			if _, ok := x.Binding.Node.(ast.FuncLit); ok {
+				panic("main must not be a function (see infer.File)")
+			}
+			// main evaluates its binding for its effects
+			return jen.Func().Id("main").Params().Block(
+				jen.Id("_").Op("=").Add(g.expr(x.Binding)),
+			)
+		}
+		if isFuncDecl(x) {
+			return g.funcDecl(x.Ident, x.Binding)
+		}
+		return jen.Var().Id(string(x.Ident)).Op("=").Add(g.expr(x.Binding))
+	case ast.TypeDecl:
+		if _, ok := x.Type.(ast.SumSpec); ok {
+			return sumType(x)
+		}
+		if len(x.Args) > 0 {
+			// aliases have been expanded, and Go only supports generic
+			// aliases as of Go 1.24, so parameterized aliases are omitted
+			return jen.Null()
+		}
+		return jen.Type().Id(x.Name).Op("=").Add(Type(x.Type))
 	default:
-		panic(fmt.Sprint("Stmt() not yet implemented for %T", stmt))
+		panic(fmt.Sprintf("Stmt() not yet implemented for %T", stmt))
 	}
 }
 
+// File renders `f`, which must have been monomorphized (see
+// `infer.Monomorphize`).
 func File(f ast.File) *jen.File {
+	g := newGenerator(f.Stmts)
 	out := jen.NewFile(f.Package)
 	for _, stmt := range f.Stmts {
-		out.Add(Stmt(stmt))
+		var doc string
+		switch x := stmt.(type) {
+		case ast.LetDecl:
+			doc = x.Doc
+		case ast.TypeDecl:
+			doc = x.Doc
+		}
+		if doc != "" {
+			for _, line := range strings.Split(doc, "\n") {
+				out.Comment(line)
+			}
+		}
+		out.Add(g.stmt(stmt))
 	}
 	return out
 }
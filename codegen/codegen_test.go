@@ -0,0 +1,92 @@
+package codegen
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+	"github.com/weberc2/gallium/combinator"
+	"github.com/weberc2/gallium/infer"
+	"github.com/weberc2/gallium/parser"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestFile compiles each Gallium file in testdata and compares the generated
+// Go with the file's golden file (`<name>.golden`), which `-update`
+// rewrites. The generated Go is also vetted (and so type-checked) by the Go
+// toolchain, if it's installed.
+func TestFile(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("testdata", "*.ga"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range sources {
+		name := strings.TrimSuffix(filepath.Base(source), ".ga")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := parser.File(combinator.NewInput(string(data)))
+			if result.Err != nil {
+				t.Fatal("Unexpected error:", result.Err)
+			}
+			file, err := infer.File(infer.Operators(), result.Value.(ast.File))
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			if file, err = infer.Monomorphize(file); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			var got bytes.Buffer
+			if err := File(file).Render(&got); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			wanted, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(wanted) {
+				t.Fatalf("WANTED:\n%s\nGOT:\n%s", wanted, got.String())
+			}
+			vet(t, got.Bytes())
+		})
+	}
+}
+
+// vet runs `go vet` on the Go source `src` in a module of its own.
+func vet(t *testing.T, src []byte) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("The Go toolchain isn't installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module generated\n\ngo 1.21\n",
+		"main.go": string(src),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "vet", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %v\n%s\n%s", err, out, src)
+	}
+}
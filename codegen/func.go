@@ -0,0 +1,177 @@
+package codegen
+
+import (
+	"strconv"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/weberc2/gallium/ast"
+)
+
+// Functions are uncurried: a function of type `a -> b -> c` is rendered as a
+// Go `func(a, b) c` rather than a `func(a) func(b) c`, so a function's arity
+// (the number of parameters of its Go function) is the number of arrows in
+// its type. This matches `ast.FuncSpec.RenderGo` and Go functions declared as
+// builtins, and it lets calls which supply every argument, e.g., `add 1 2`,
+// be rendered as direct calls, e.g., `add(1, 2)`. Only partial application,
+// e.g., `add 1`, requires a closure.
+
+// signature returns the argument types and the return type of the Go
+// function for the function type `t`, e.g., `[a, b]` and `c` for
+// `a -> b -> c`. If `t` isn't a function type, there are no arguments and the
+// return type is `t`.
+func signature(t ast.Type) ([]ast.Type, ast.Type) {
+	var args []ast.Type
+	for {
+		fs, ok := t.(ast.FuncSpec)
+		if !ok {
+			return args, t
+		}
+		args = append(args, fs.Arg)
+		t = fs.Ret
+	}
+}
+
+// function returns the parameters, return type and body (see body) of the Go
+// function for the function literal `expr`. Curried function literals are
+// merged, so `x -> y -> x + y` has the parameters `x` and `y`. If there are
+// fewer literals than arguments (e.g., `x -> add x`), the function is
+// eta-expanded: it has a parameter named `_p<i>` for each of the remaining
+// arguments and its body is applied to those parameters. A literal whose
+// argument has the name of an outer one's is treated as the body, since Go
+// parameters can't shadow each other.
+func (g generator) function(
+	expr ast.Expr,
+) ([]jen.Code, ast.Type, []jen.Code) {
+	args, ret := signature(expr.Type)
+	params := make([]jen.Code, len(args))
+	var names []ast.Ident
+	expanded := false
+	for i, arg := range args {
+		if fl, ok := expr.Node.(ast.FuncLit); ok && !expanded &&
+			!containsIdent(names, fl.Arg) {
+			params[i] = jen.Id(string(fl.Arg)).Add(Type(arg))
+			names = append(names, fl.Arg)
+			expr = fl.Body
+			continue
+		}
+
+		// the body is applied to the parameter in the AST so the call is
+		// uncurried along with any call in the body, e.g., `x -> add x` is
+		// rendered as `func(x int, _p1 int) int { return add(x, _p1) }`
+		expanded = true
+		name := "_p" + strconv.Itoa(i)
+		params[i] = jen.Id(name).Add(Type(arg))
+		names = append(names, ast.Ident(name))
+		expr = ast.Expr{
+			Type: expr.Type.(ast.FuncSpec).Ret,
+			Node: ast.Call{
+				Fn:  expr,
+				Arg: ast.Expr{Type: arg, Node: ast.Ident(name)},
+			},
+			Span: expr.Span,
+		}
+	}
+	return params, ret, g.shadow(names...).body(expr, names)
+}
+
+func containsIdent(idents []ast.Ident, ident ast.Ident) bool {
+	for _, x := range idents {
+		if x == ident {
+			return true
+		}
+	}
+	return false
+}
+
+// uncurry returns the function which the call `expr` ultimately applies and
+// the arguments to which it's applied, e.g., `f` and `[a, b]` for `f a b`.
+func uncurry(expr ast.Expr) (ast.Expr, []ast.Expr) {
+	var args []ast.Expr
+	for {
+		call, ok := expr.Node.(ast.Call)
+		if !ok {
+			return expr, args
+		}
+		args = append([]ast.Expr{call.Arg}, args...)
+		expr = call.Fn
+	}
+}
+
+// apply renders the application of the function `fn` to `args`, whose
+// result is of type `t`. If every argument is supplied, the function is
+// called directly. Otherwise it's partially applied: the result is a
+// closure which takes the remaining arguments. The supplied arguments (and
+// the function) are evaluated when the function is partially applied rather
+// than each time the closure is called:
+//
+//	func() func(int) int {
+//		_a0 := 1
+//		return func(_p1 int) int {
+//			return add(_a0, _p1)
+//		}
+//	}()
+func (g generator) apply(
+	fn ast.Expr,
+	args []ast.Expr,
+	t ast.Type,
+) *jen.Statement {
+	types, ret := signature(fn.Type)
+	if len(args) >= len(types) {
+		values := make([]jen.Code, len(args))
+		for i, arg := range args {
+			values[i] = g.expr(arg)
+		}
+		return g.call(fn, values)
+	}
+
+	var stmts []jen.Code
+	if _, ok := fn.Node.(ast.Ident); !ok {
+		stmts = append(stmts, jen.Id("_f").Op(":=").Add(g.expr(fn)))
+		fn = ast.Expr{Type: fn.Type, Node: ast.Ident("_f"), Span: fn.Span}
+	}
+	values := make([]jen.Code, len(types))
+	for i, arg := range args {
+		values[i] = jen.Id("_a" + strconv.Itoa(i))
+		stmts = append(stmts, jen.Id("_a"+strconv.Itoa(i)).Op(":=").Add(
+			g.expr(arg),
+		))
+	}
+	params := make([]jen.Code, len(types)-len(args))
+	for i := len(args); i < len(types); i++ {
+		values[i] = jen.Id("_p" + strconv.Itoa(i))
+		params[i-len(args)] = jen.Id("_p" + strconv.Itoa(i)).Add(
+			Type(types[i]),
+		)
+	}
+	closure := jen.Func().Params(params...).Add(Type(ret)).Block(
+		jen.Return(g.call(fn, values)),
+	)
+	if len(stmts) < 1 {
+		return closure
+	}
+	stmts = append(stmts, jen.Return(closure))
+	return jen.Func().Params().Add(Type(t)).Block(stmts...).Call()
+}
+
+// call renders a call to the function `fn` with every argument supplied.
+// Operators (e.g., `+`), which are desugared into functions, are rendered
+// natively and constructors are rendered as values of their sum types. A
+// polymorphic top-level function is only instantiated explicitly if Go can't
+// infer its type arguments from the call's arguments.
+func (g generator) call(fn ast.Expr, values []jen.Code) *jen.Statement {
+	if ident, ok := fn.Node.(ast.Ident); ok {
+		if ident.IsOperator() && len(values) == 2 {
+			return jen.Parens(
+				jen.Add(values[0]).Op(string(ident)).Add(values[1]),
+			)
+		}
+		if v, ok := constructor(ident, fn.Type); ok {
+			_, ret := signature(fn.Type)
+			return construct(ret, v, values[0])
+		}
+		if _, ok := g.generics[ident]; ok && g.inferable(ident) {
+			return jen.Id(string(ident)).Call(values...)
+		}
+	}
+	return jen.Add(g.expr(fn)).Call(values...)
+}
@@ -0,0 +1,170 @@
+package codegen
+
+import (
+	"github.com/dave/jennifer/jen"
+	"github.com/weberc2/gallium/ast"
+	"github.com/weberc2/gallium/infer"
+)
+
+// Polymorphic top-level functions are rendered as Go generic functions with
+// a type parameter for each of the type variables in their types, e.g.,
+// `let id = x -> x;` (whose type is `'a -> 'a`) is rendered as
+// `func id[_A any](x _A) _A { return x }`. A type variable `'a` is rendered
+// as the type parameter `_A` (see Type). Polymorphic bindings which can't be
+// rendered this way (e.g., functions of open records) must have been
+// specialized by `infer.Monomorphize`.
+
+// generator renders the expressions of a file. It holds the types of the
+// file's polymorphic top-level functions so references to them can be
+// instantiated (see instantiate), and the type variables of each which are
+// restricted to equality types (see typeParams).
+type generator struct {
+	generics    map[ast.Ident]ast.Type
+	comparables map[ast.Ident][]ast.TypeVar
+}
+
+// newGenerator returns the generator for the statements of a file.
+func newGenerator(stmts []ast.Stmt) generator {
+	comparables, err := infer.Comparables(stmts)
+	if err != nil {
+		panic(err)
+	}
+	g := generator{
+		generics:    map[ast.Ident]ast.Type{},
+		comparables: comparables,
+	}
+	for _, stmt := range stmts {
+		letDecl, ok := stmt.(ast.LetDecl)
+		if ok && isFuncDecl(letDecl) &&
+			len(infer.FreeTypeVars(letDecl.Binding.Type)) > 0 {
+			g.generics[letDecl.Ident] = letDecl.Binding.Type
+		}
+	}
+	return g
+}
+
+// shadow returns the generator for a scope in which `idents` are bound to
+// local variables, which shadow any top-level functions of the same names.
+func (g generator) shadow(idents ...ast.Ident) generator {
+	var generics map[ast.Ident]ast.Type
+	for _, ident := range idents {
+		if _, found := g.generics[ident]; !found {
+			continue
+		}
+		if generics == nil {
+			generics = make(map[ast.Ident]ast.Type, len(g.generics))
+			for ident, t := range g.generics {
+				generics[ident] = t
+			}
+		}
+		delete(generics, ident)
+	}
+	if generics == nil {
+		return g
+	}
+	return generator{generics: generics, comparables: g.comparables}
+}
+
+// typeParams returns the type parameters of the Go generic function for the
+// top-level function `ident` of type `t`. A type parameter is constrained by
+// `comparable` if its values are compared, either directly or by passing them
+// to another function which compares them (see infer.Comparables), since Go
+// only allows values of a type parameter to be compared if it's constrained
+// by `comparable`. Other type parameters are constrained by `any`.
+func (g generator) typeParams(ident ast.Ident, t ast.Type) []jen.Code {
+	var params []jen.Code
+	for _, tv := range infer.FreeTypeVars(t) {
+		if infer.ContainsTypeVar(g.comparables[ident], tv) {
+			params = append(params, Type(tv).Comparable())
+		} else {
+			params = append(params, Type(tv).Any())
+		}
+	}
+	return params
+}
+
+// typeArgs returns the type arguments with which the generic function of
+// type `generic` is instantiated to be of type `t`, i.e., the types which
+// its type variables stand for in `t`.
+func typeArgs(generic, t ast.Type) []jen.Code {
+	types := map[ast.TypeVar]ast.Type{}
+	var visit func(generic, t ast.Type)
+	visit = func(generic, t ast.Type) {
+		switch x := generic.(type) {
+		case ast.TypeVar:
+			types[x] = t
+		case ast.FuncSpec:
+			fs := t.(ast.FuncSpec)
+			visit(x.Arg, fs.Arg)
+			visit(x.Ret, fs.Ret)
+		case ast.TupleSpec:
+			for i, generic := range x {
+				visit(generic, t.(ast.TupleSpec)[i])
+			}
+		case ast.TypeRef:
+			for i, generic := range x.Args {
+				visit(generic, t.(ast.TypeRef).Args[i])
+			}
+		case ast.RecordSpec:
+			// both are closed records with the same fields, which are sorted
+			// by name
+			for i, f := range x.Fields {
+				visit(f.Type, t.(ast.RecordSpec).Fields[i].Type)
+			}
+		}
+	}
+	visit(generic, t)
+
+	var args []jen.Code
+	for _, tv := range infer.FreeTypeVars(generic) {
+		args = append(args, Type(types[tv]))
+	}
+	return args
+}
+
+// instantiate renders a reference to the polymorphic top-level function
+// `ident` at the type `t`, e.g., `id[int]`. Go can only infer the type
+// arguments of a generic function from the arguments of a call (see
+// inferable), so any other reference (e.g., passing `id` to another function)
+// must be instantiated explicitly.
+func (g generator) instantiate(ident ast.Ident, t ast.Type) *jen.Statement {
+	return jen.Id(string(ident)).Types(typeArgs(g.generics[ident], t)...)
+}
+
+// inferable returns true if Go can infer the type arguments of a call to the
+// polymorphic top-level function `ident` which supplies every argument, i.e.,
+// if each of its type variables occurs in the types of its arguments rather
+// than only in its return type. Go doesn't infer type arguments from those of
+// sum types (which are rendered as interfaces), so type variables which only
+// occur as arguments of sum types don't count.
+func (g generator) inferable(ident ast.Ident) bool {
+	var inArgs []ast.TypeVar
+	var visit func(t ast.Type)
+	visit = func(t ast.Type) {
+		switch x := t.(type) {
+		case ast.TypeVar:
+			inArgs = append(inArgs, x)
+		case ast.FuncSpec:
+			visit(x.Arg)
+			visit(x.Ret)
+		case ast.TupleSpec:
+			for _, t := range x {
+				visit(t)
+			}
+		case ast.RecordSpec:
+			for _, f := range x.Fields {
+				visit(f.Type)
+			}
+		}
+	}
+	args, _ := signature(g.generics[ident])
+	for _, arg := range args {
+		visit(arg)
+	}
+	for _, tv := range infer.FreeTypeVars(g.generics[ident]) {
+		if !infer.ContainsTypeVar(inArgs, tv) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package codegen
+
+import (
+	"strconv"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/weberc2/gallium/ast"
+)
+
+// match renders a match expression as an immediately-invoked function which
+// returns the body of the first case whose pattern matches. If the matched
+// value is of a sum type, the function switches on the value's type (i.e.,
+// its variant) and each clause tries the cases which may match that variant
+// in order:
+//
+//	func() int {
+//		_v0 := s
+//		switch _v1 := _v0.(type) {
+//		case Circle:
+//			r := _v1._0
+//			_ = r
+//			return r
+//		default:
+//			return 0
+//		}
+//	}()
+//
+// Otherwise the cases are tried in order. Since the match has been checked
+// for exhaustiveness, the panics which end the function are unreachable;
+// they're only there to satisfy the Go compiler.
+func (g generator) match(expr ast.Expr, m ast.Match) *jen.Statement {
+	subject := jen.Id("_v0")
+	body := []jen.Code{jen.Add(subject).Op(":=").Add(g.expr(m.Expr))}
+	if ref, ok := m.Expr.Type.(ast.TypeRef); ok && ref.Variants() != nil {
+		body = append(body, g.typeSwitch(ref, m.Cases, subject))
+	} else {
+		body = append(body, g.cases(m.Cases, subject)...)
+	}
+	return jen.Func().Params().Add(Type(expr.Type)).Block(body...).Call()
+}
+
+// typeSwitch returns a type switch on `subject` (whose type is the sum type
+// `t`) with a clause for each of the variants matched by a constructor
+// pattern in `cs` and a default clause for the remaining variants.
+func (g generator) typeSwitch(
+	t ast.TypeRef,
+	cs []ast.Case,
+	subject jen.Code,
+) *jen.Statement {
+	value := jen.Id("_v1")
+	var clauses []jen.Code
+	var bind bool
+	seen := map[string]bool{}
+	for _, c := range cs {
+		cp, ok := c.Pattern.Node.(ast.CtorPattern)
+		if !ok || seen[cp.Name()] {
+			continue
+		}
+		seen[cp.Name()] = true
+
+		var stmts []jen.Code
+		exhaustive := false
+		for _, c := range cs {
+			x, ok := c.Pattern.Node.(ast.CtorPattern)
+			switch {
+			case !ok:
+				stmts = append(stmts, g.matchCase(c, subject, 2)...)
+				exhaustive = irrefutable(c.Pattern)
+			case x.Name() != cp.Name():
+				continue
+			case x.Arg.Node == nil:
+				stmts = append(stmts, g.body(c.Body, nil)...)
+				exhaustive = true
+			default:
+				if _, ok := x.Arg.Node.(ast.WildcardPattern); !ok {
+					bind = true
+				}
+				stmts = append(stmts, g.matchCase(
+					ast.Case{Pattern: x.Arg, Body: c.Body},
+					jen.Add(value).Dot("_0"),
+					2,
+				)...)
+				exhaustive = irrefutable(x.Arg)
+			}
+			if exhaustive {
+				break
+			}
+		}
+		if !exhaustive {
+			stmts = append(stmts, unreachable())
+		}
+		clauses = append(
+			clauses,
+			jen.Case(variant(t, cp.Name())).Block(stmts...),
+		)
+	}
+
+	var defaults []ast.Case
+	for _, c := range cs {
+		if _, ok := c.Pattern.Node.(ast.CtorPattern); !ok {
+			defaults = append(defaults, c)
+		}
+	}
+	clauses = append(
+		clauses,
+		jen.Default().Block(g.cases(defaults, subject)...),
+	)
+
+	if bind {
+		return jen.Switch(
+			jen.Add(value).Op(":=").Add(subject).Assert(jen.Type()),
+		).Block(clauses...)
+	}
+	return jen.Switch(jen.Add(subject).Assert(jen.Type())).Block(clauses...)
+}
+
+// cases returns statements which return the body of the first of `cs` whose
+// pattern matches `subject`.
+func (g generator) cases(cs []ast.Case, subject jen.Code) []jen.Code {
+	var out []jen.Code
+	for _, c := range cs {
+		out = append(out, g.matchCase(c, subject, 1)...)
+		if irrefutable(c.Pattern) {
+			return out
+		}
+	}
+	return append(out, unreachable())
+}
+
+func unreachable() *jen.Statement {
+	return jen.Panic(jen.Lit("unreachable"))
+}
+
+// matchCase returns statements which return the case's body if `subject`
+// matches its pattern, and which otherwise fall through. Temporary variables
+// are named `_v<depth>`, `_v<depth+1>`, etc. so the temporaries of nested
+// patterns don't shadow those of their parents.
+func (g generator) matchCase(
+	c ast.Case,
+	subject jen.Code,
+	depth int,
+) []jen.Code {
+	return g.pattern(c.Pattern, subject, depth, func() []jen.Code {
+		vars := c.Pattern.Vars()
+		return g.shadow(vars...).body(c.Body, vars)
+	})
+}
+
+// pattern returns statements which bind the variables of `p` to the parts of
+// `subject` they match and then run the statements returned by `then` if
+// `subject` matches `p`.
+func (g generator) pattern(
+	p ast.Pattern,
+	subject jen.Code,
+	depth int,
+	then func() []jen.Code,
+) []jen.Code {
+	switch x := p.Node.(type) {
+	case ast.WildcardPattern:
+		return then()
+	case ast.VarPattern:
+		// the body needn't use the variable, but Go requires that it's used
+		return append(
+			[]jen.Code{
+				jen.Id(string(x)).Op(":=").Add(subject),
+				jen.Id("_").Op("=").Id(string(x)),
+			},
+			then()...,
+		)
+	case ast.LitPattern:
+		return []jen.Code{jen.If(
+			jen.Add(subject).Op("==").Add(g.expr(ast.Expr{Node: x.Lit})),
+		).Block(then()...)}
+	case ast.TuplePattern:
+		var elts func(i int) []jen.Code
+		elts = func(i int) []jen.Code {
+			if i >= len(x) {
+				return then()
+			}
+			return g.pattern(
+				x[i],
+				jen.Add(subject).Dot("_"+strconv.Itoa(i)),
+				depth,
+				func() []jen.Code { return elts(i + 1) },
+			)
+		}
+		return elts(0)
+	case ast.CtorPattern:
+		variant := jen.Id("_v" + strconv.Itoa(depth))
+		stmts := then
+		if _, ok := x.Arg.Node.(ast.WildcardPattern); ok || x.Arg.Node == nil {
+			variant = jen.Id("_")
+		} else {
+			stmts = func() []jen.Code {
+				return g.pattern(
+					x.Arg,
+					jen.Add(variant).Dot("_0"),
+					depth+1,
+					then,
+				)
+			}
+		}
+		return []jen.Code{jen.If(
+			jen.List(variant, jen.Id("_ok")).Op(":=").Add(subject).Assert(
+				generic(x.Name(), p.Type.(ast.TypeRef).Args),
+			),
+			jen.Id("_ok"),
+		).Block(stmts()...)}
+	default:
+		panic("pattern() not implemented for " + p.String())
+	}
+}
+
+// irrefutable returns true if `p` matches every value of its type.
+func irrefutable(p ast.Pattern) bool {
+	switch x := p.Node.(type) {
+	case ast.WildcardPattern, ast.VarPattern:
+		return true
+	case ast.TuplePattern:
+		for _, p := range x {
+			if !irrefutable(p) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -5,42 +5,92 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 )
 
-// Input is the input to a parser.
-type Input string
+// Position is a location in the input.
+type Position struct {
+	// Offset is the byte offset from the start of the input, starting at 0.
+	Offset int
+
+	// Line is the line number, starting at 1.
+	Line int
+
+	// Column is the rune offset from the start of the line, starting at 1.
+	Column int
+}
+
+// String returns the position in the form "line:column".
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Input is the input to a parser. It holds the text which remains to be
+// parsed as well as the position of that text in the original source.
+type Input struct {
+	text string
+	pos  Position
+
+	// memo holds the results of memoized parsers (see Memo) for the parse
+	// of the original source. It's shared by every input derived from the
+	// original.
+	memo memoTable
+}
+
+// NewInput returns an Input for the source text `s`, positioned at its start.
+func NewInput(s string) Input {
+	return Input{
+		text: s,
+		pos:  Position{Line: 1, Column: 1},
+		memo: memoTable{},
+	}
+}
+
+// String returns the text which remains to be parsed.
+func (i Input) String() string { return i.text }
+
+// Pos returns the position of the input in the original source.
+func (i Input) Pos() Position { return i.pos }
 
 // Cons returns the first rune and the remaining input.
 func (i Input) Cons() (rune, Input) {
-	r, sz := utf8.DecodeRuneInString(string(i))
+	r, sz := utf8.DecodeRuneInString(i.text)
 	if r == utf8.RuneError {
 		if sz == 0 { // According to utf8 docs, (RuneError, 0) means eof
-			return rune(0), ""
+			return rune(0), i
 		}
 		// According to utf8 docs, (RuneError, 1) means invalid utf-8
 		panic("Invalid utf-8")
 	}
-	return r, i[sz:]
+	pos := Position{Offset: i.pos.Offset + sz, Line: i.pos.Line}
+	if r == '\n' {
+		pos.Line++
+		pos.Column = 1
+	} else {
+		pos.Column = i.pos.Column + 1
+	}
+	return r, Input{text: i.text[sz:], pos: pos, memo: i.memo}
 }
 
-func (i Input) cut(n int) Input {
-	j := strings.IndexAny(string(i), " \n\t\r")
-	if j < 0 {
-		return Input([]rune(string(i))[:n])
+// advance returns the input after the first `n` bytes.
+func (i Input) advance(n int) Input {
+	end := i.pos.Offset + n
+	for i.pos.Offset < end {
+		_, i = i.Cons()
 	}
-	return Input([]rune(string(i))[:j])
+	return i
 }
 
 // Sample returns a string consisting of the next `n` characters or as many as
 // are left in the input. If `n` is smaller than `len(i)`, the result string
 // will be ellipsized. This is mostly just useful for error messaging.
 func (i Input) Sample(n int) string {
-	if n >= len(i) {
-		return string(i)
+	if n >= len(i.text) {
+		return i.text
 	}
-	return string([]rune(string(i))[:n]) + "..."
+	return string([]rune(i.text)[:n]) + "..."
 }
 
 // MapFunc maps a parse result value from one value/type to another.
@@ -49,6 +99,69 @@ type MapFunc func(interface{}) interface{}
 // MapSliceFunc maps a parse result slice value from one type to another
 type MapSliceFunc func([]interface{}) interface{}
 
+// MapSpanFunc maps a parse result value from one value/type to another given
+// the positions at which the parser started and stopped consuming input.
+type MapSpanFunc func(v interface{}, start, end Position) interface{}
+
+// Failure describes how far a parse got before failing: the position of the
+// failure and the things which were expected there. Failures from different
+// parsers are merged, so the failure reported for a parse is the one which got
+// furthest into the input, listing everything that could have appeared at
+// that point.
+type Failure struct {
+	// Pos is the position at which the failure occurred.
+	Pos Position
+
+	// Expected describes the things which could have appeared at Pos, e.g.,
+	// "')'" or "identifier".
+	Expected []string
+}
+
+// IsValid returns true if the failure has a position.
+func (f Failure) IsValid() bool { return f.Pos.Line > 0 }
+
+// Message returns a description of the expected things, e.g.,
+// "expected ')' or ','".
+func (f Failure) Message() string {
+	switch len(f.Expected) {
+	case 0:
+		return "unexpected input"
+	case 1:
+		return "expected " + f.Expected[0]
+	default:
+		last := len(f.Expected) - 1
+		return "expected " + strings.Join(f.Expected[:last], ", ") +
+			" or " + f.Expected[last]
+	}
+}
+
+// Error implements the error interface for Failure.
+func (f Failure) Error() string {
+	return "at " + f.Pos.String() + " " + f.Message()
+}
+
+// Merge returns whichever of `f` and `other` got further into the input. If
+// they failed at the same position, their expectations are combined.
+func (f Failure) Merge(other Failure) Failure {
+	switch {
+	case !other.IsValid() || f.IsValid() && f.Pos.Offset > other.Pos.Offset:
+		return f
+	case !f.IsValid() || other.Pos.Offset > f.Pos.Offset:
+		return other
+	}
+	expected := append([]string(nil), f.Expected...)
+OUTER:
+	for _, e := range other.Expected {
+		for _, existing := range expected {
+			if e == existing {
+				continue OUTER
+			}
+		}
+		expected = append(expected, e)
+	}
+	return Failure{Pos: f.Pos, Expected: expected}
+}
+
 // Result represents the result of a parse.
 type Result struct {
 	// ParserName is the name of the parser that produced the result.
@@ -67,6 +180,13 @@ type Result struct {
 	// Err is set if the parser encountered a syntax error. Value should be
 	// ignored if this is non-nil.
 	Err error
+
+	// Furthest is the furthest failure encountered while producing the
+	// result. This is tracked even for successful results, since a later
+	// failure is often better explained by an earlier alternative which got
+	// further into the input (e.g., an optional argument list which stopped
+	// at a missing ')').
+	Furthest Failure
 }
 
 // Map applies `f` to `r.Value` if `r` is valid; otherwise it short circuits
@@ -80,6 +200,7 @@ func (r Result) Map(f func(interface{}) interface{}) Result {
 		Value:      f(r.Value),
 		Rest:       r.Rest,
 		Err:        r.Err,
+		Furthest:   r.Furthest,
 	}
 }
 
@@ -89,40 +210,46 @@ func (r Result) Then(p Parser) Result {
 	if r.Err != nil {
 		return r
 	}
-	return p(r.Rest)
+	next := p(r.Rest)
+	next.Furthest = r.Furthest.Merge(next.Furthest)
+	return next
 }
 
-// OK returns a valid result from the provided value and input. The result's
-// parser name will be that of OK's caller.
+// OK returns a valid result from the provided value and input. The result
+// has no parser name; parsers name their results when they're built (see
+// Parser.Rename), so parsing doesn't pay to look a name up for each result.
 func OK(value interface{}, rest Input) Result {
-	pc, _, _, ok := runtime.Caller(1)
-	if !ok {
-		return Result{"???", value, rest, nil}
-	}
-	return Result{runtime.FuncForPC(pc).Name(), value, rest, nil}
+	return Result{Value: value, Rest: rest}
 }
 
-// ERR returns an error result from the provided error and input. The result's
-// parser name will be that of ERR's caller. The "Rest" field will contain the
-// value passed as input, which should be the input provided to the parser when
-// it failed.
+// ERR returns an error result from the provided error and input. Like OK's
+// result, it has no parser name. The "Rest" field will contain the value
+// passed as input, which should be the input provided to the parser when it
+// failed. The result's furthest failure is taken from `err` if it's a Failure
+// or a Result.
 func ERR(err error, input Input) Result {
-	pc, _, _, ok := runtime.Caller(1)
-	if !ok {
-		return Result{"???", nil, "", err}
+	var furthest Failure
+	switch e := err.(type) {
+	case Failure:
+		furthest = e
+	case Result:
+		furthest = e.Furthest
 	}
-	return Result{runtime.FuncForPC(pc).Name(), nil, input, err}
+	return Result{Rest: input, Err: err, Furthest: furthest}
 }
 
-// Error implements the error interface for Result. This should only be used to
-// nest a Result as the error of another result, and so build a sort of parser
-// stack trace. Indeed, Error returns a string containing the result's parser
-// information and the error it encountered, which is always a Result until the
-// very deepest error.
+// Error implements the error interface for Result. It describes the furthest
+// failure encountered by the parse, e.g., "at 3:14 expected ')' or ','". If
+// there is no such failure, it falls back to the result's parser information
+// and the error it encountered.
 func (r Result) Error() string {
+	if r.Furthest.IsValid() {
+		return r.Furthest.Error()
+	}
 	return fmt.Sprintf(
-		"%s(%#v):\n%s",
+		"%s(%s: %#v): %v",
 		r.ParserName,
+		r.Rest.Pos(),
 		r.Rest.Sample(15),
 		r.Err,
 	)
@@ -131,15 +258,17 @@ func (r Result) Error() string {
 // Rename takes a name and returns a copy of the original result except with
 // the ParserName field set to `name`.
 func (r Result) Rename(name string) Result {
-	return Result{ParserName: name, Value: r.Value, Rest: r.Rest, Err: r.Err}
+	r.ParserName = name
+	return r
 }
 
 // Recover takes an input returns the original Result if it was successful,
 // otherwise it returns a new successful Result with the same ParserName but
-// Value is set to nil and Rest is set to `input`.
+// Value is set to nil and Rest is set to `input`. The furthest failure is
+// preserved either way.
 func (r Result) Recover(input Input) Result {
 	if r.Err != nil {
-		return Result{r.ParserName, nil, input, nil}
+		return Result{r.ParserName, nil, input, nil, r.Furthest}
 	}
 	return r
 }
@@ -166,9 +295,97 @@ func (p Parser) Map(f MapFunc) Parser {
 	return func(input Input) Result { return p(input).Map(f) }
 }
 
+// MapSpan is like Map, except `f` also receives the positions at which the
+// original parser started and stopped consuming input.
+func (p Parser) MapSpan(f MapSpanFunc) Parser {
+	return func(input Input) Result {
+		r := p(input)
+		return r.Map(func(v interface{}) interface{} {
+			return f(v, input.Pos(), r.Rest.Pos())
+		})
+	}
+}
+
+// Label returns a parser which reports failures at its input position as
+// expecting `name` rather than whatever the original parser expected there.
+// For example, a parser for expressions might be labeled "expression" rather
+// than reporting that it expected an identifier or an integer or a '(' and so
+// on. Failures which occur after the parser has consumed some input are
+// reported unchanged. If `name` is empty, such failures expect nothing, which
+// hides them from error messages.
+func (p Parser) Label(name string) Parser {
+	return func(input Input) Result {
+		r := p(input)
+		if r.Furthest.Pos.Offset == input.Pos().Offset {
+			r.Furthest.Expected = nil
+			if name != "" {
+				r.Furthest.Expected = []string{name}
+			}
+		}
+		return r
+	}
+}
+
+// Token is like Label, but it also discards the failures encountered by a
+// successful parse up to the point where the parse stopped. It's intended for
+// lexical tokens like identifiers, whose failures there only describe how the
+// token could have been longer.
+func (p Parser) Token(name string) Parser {
+	p = p.Label(name)
+	return func(input Input) Result {
+		r := p(input)
+		if r.Err == nil && r.Furthest.Pos.Offset <= r.Rest.pos.Offset {
+			r.Furthest = Failure{}
+		}
+		return r
+	}
+}
+
+type memoKey struct {
+	parser int64
+	offset int
+}
+
+type memoTable map[memoKey]Result
+
+// memoParsers is the number of parsers created by Memo. It's used to give each
+// memoized parser a unique identity.
+var memoParsers int64
+
+// Memo returns a parser which behaves like `p` except that it remembers its
+// result for each position in the input, so parsing the same prefix more than
+// once (e.g., in Any(Seq(Atom, WS, Atom), Atom)) only invokes `p` once per
+// position. This is often called "packrat parsing", and it's what keeps
+// heavily backtracking grammars from taking exponential time.
+//
+// Results are remembered per parse; that is, per input returned by NewInput.
+// Each call to Memo creates a new parser identity, so it should be called once
+// per parser (e.g., when initializing a package-level variable) rather than
+// every time the parser is invoked. `p` must always return the same result
+// for the same input. The memoized parser isn't safe for concurrent use on
+// the same input.
+func Memo(p Parser) Parser {
+	id := atomic.AddInt64(&memoParsers, 1)
+	return func(input Input) Result {
+		if input.memo == nil {
+			return p(input)
+		}
+		key := memoKey{parser: id, offset: input.pos.Offset}
+		if r, found := input.memo[key]; found {
+			return r
+		}
+		r := p(input)
+		input.memo[key] = r
+		return r
+	}
+}
+
 // Wrap returns a parser whose resultant caller information are those of Wrap's
 // own caller. So if Foo() calls Wrap(), the returned parser will produce
-// Results with a ParserName of "Foo".
+// Results with a ParserName of "Foo". Wrap looks its caller up on the stack,
+// so it should only be called when a parser is built once (e.g., when
+// initializing a package-level variable); a parser which is built each time
+// it's invoked should be given a constant name with Rename instead.
 func (p Parser) Wrap() Parser {
 	name := "???"
 	pc, _, _, ok := runtime.Caller(1)
@@ -199,73 +416,87 @@ func (p Parser) Get(i int) Parser {
 	return p.MapSlice(func(vs []interface{}) interface{} { return vs[i] })
 }
 
+// expected returns an error result for `input` which expects `what`.
+func expected(input Input, what string) Result {
+	return ERR(Failure{Pos: input.Pos(), Expected: []string{what}}, input)
+}
+
+// quote returns the description of the rune `r` used in failure messages.
+func quote(r rune) string {
+	if r == 0 {
+		return "end of input"
+	}
+	return "'" + string(r) + "'"
+}
+
 func lit(r rune, input Input) Result {
 	head, tail := input.Cons()
 	if head == r {
 		return OK(head, tail)
 	}
-	return ERR(
-		fmt.Errorf("Wanted %#v, got %#v", string(r), string(head)),
-		input,
-	)
+	return expected(input, quote(r))
 }
 
 func strlit(s string, input Input) Result {
-	if !strings.HasPrefix(string(input), s) {
-		return ERR(
-			fmt.Errorf("Wanted '%s'; got '%s'", s, input.cut(len(s))),
-			input,
-		)
+	if !strings.HasPrefix(input.text, s) {
+		return expected(input, strconv.Quote(s))
 	}
-	return OK(s, input[len(s):])
+	return OK(s, input.advance(len(s)))
 }
 
 // Lit returns a parser that expects the first rune to match `r`. On success,
 // `r` is returned as the result value.
 func Lit(r rune) Parser {
-	return Parser(func(input Input) Result { return lit(r, input) }).Wrap()
+	return Parser(func(input Input) Result {
+		return lit(r, input)
+	}).Rename("Lit")
 }
 
 // NotLit takes a rune `r` and returns a Parser that expects the first rune of
-// its input to be anything except `r`. If successful, it returns the non-`r`
-// rune as the result value.
+// its input to be anything except `r` (or the end of the input). If
+// successful, it returns the non-`r` rune as the result value.
 func NotLit(r rune) Parser {
 	return Parser(func(input Input) Result {
 		head, tail := input.Cons()
-		if head == r {
-			return ERR(
-				fmt.Errorf(
-					"Wanted anything but '%s', but got it anyway",
-					string(r),
-				),
-				input,
-			)
+		if head == r || head == 0 {
+			// the only thing which could have appeared is whatever isn't
+			// `r`, which isn't worth reporting
+			return ERR(Failure{Pos: input.Pos()}, input)
 		}
 		return OK(head, tail)
-	}).Wrap()
+	}).Rename("NotLit")
 }
 
 // StrLit returns a parser that expects the input to have the prefix `s`. On
 // success, the result value is `s`.
 func StrLit(s string) Parser {
-	return Parser(func(input Input) Result { return strlit(s, input) }).Wrap()
+	return Parser(func(input Input) Result {
+		return strlit(s, input)
+	}).Rename("StrLit")
 }
 
 // Seq returns a parser that expects the input to contain all of its input
 // parsers in order. If successful, the result value will be a slice of values,
 // one per parser, in parser order.
 func Seq(parsers ...Parser) Parser {
-	return func(input Input) Result {
+	return Parser(func(input Input) Result {
 		r := Result{Rest: input}
+		var furthest Failure
 		values := make([]interface{}, len(parsers))
 		for i, parser := range parsers {
-			if r = parser(r.Rest); r.Err != nil {
-				return ERR(r, input)
+			r = parser(r.Rest)
+			furthest = furthest.Merge(r.Furthest)
+			if r.Err != nil {
+				result := ERR(r, input)
+				result.Furthest = furthest
+				return result
 			}
 			values[i] = r.Value
 		}
-		return OK(values, r.Rest)
-	}
+		result := OK(values, r.Rest)
+		result.Furthest = furthest
+		return result
+	}).Rename("Seq")
 }
 
 // UnicodeClass represents a class of unicode characters.
@@ -275,22 +506,26 @@ type UnicodeClass struct {
 
 	// RangeTable is the unicode range table
 	RangeTable *unicode.RangeTable
+
+	// Description describes the class in failure messages, e.g., "digit"
+	Description string
 }
 
 var (
 	// UnicodeClassDigit is the "Digit" unicode class
-	UnicodeClassDigit = UnicodeClass{"Digit", unicode.Digit}
+	UnicodeClassDigit = UnicodeClass{"Digit", unicode.Digit, "digit"}
 
 	// UnicodeClassLetter is the "Letter" unicode class
-	UnicodeClassLetter = UnicodeClass{"Letter", unicode.Letter}
+	UnicodeClassLetter = UnicodeClass{"Letter", unicode.Letter, "letter"}
 
 	// UnicodeClassSpace is the "Space" unicode class
-	UnicodeClassSpace = UnicodeClass{"Space", unicode.Space}
+	UnicodeClassSpace = UnicodeClass{"Space", unicode.Space, "space"}
 
 	// UnicodeClassWhiteSpace is the "WhiteSpace" unicode class
 	UnicodeClassWhiteSpace = UnicodeClass{
 		"WhiteSpace",
 		unicode.Pattern_White_Space,
+		"whitespace",
 	}
 )
 
@@ -302,11 +537,8 @@ func IsClass(class UnicodeClass) Parser {
 		if unicode.Is(class.RangeTable, head) {
 			return OK(head, rest)
 		}
-		return ERR(
-			fmt.Errorf("Wanted <%s>; got %#v", class.Name, string(head)),
-			input,
-		)
-	}).Wrap()
+		return expected(input, class.Description)
+	}).Rename("IsClass")
 }
 
 // Repeat takes a parser and continues to invoke it until the input fails to
@@ -318,15 +550,19 @@ func IsClass(class UnicodeClass) Parser {
 func Repeat(p Parser) Parser {
 	return Parser(func(input Input) Result {
 		var values []interface{}
+		var furthest Failure
 		for {
 			r := p(input)
+			furthest = furthest.Merge(r.Furthest)
 			if r.Err != nil {
-				return OK(values, input)
+				result := OK(values, input)
+				result.Furthest = furthest
+				return result
 			}
 			values = append(values, r.Value)
 			input = r.Rest
 		}
-	}).Wrap()
+	}).Rename("Repeat")
 }
 
 // OneOrMore takes a parser and expects at least one consecutive match.
@@ -338,7 +574,7 @@ func OneOrMore(p Parser) Parser {
 		values := v.([]interface{})
 		head, tail := values[0], values[1]
 		return append([]interface{}{head}, tail.([]interface{})...)
-	}).Wrap()
+	}).Rename("OneOrMore")
 }
 
 // Opt takes an input parser and returns a parser that attempts to invoke the
@@ -348,32 +584,66 @@ func OneOrMore(p Parser) Parser {
 func Opt(p Parser) Parser {
 	return Parser(func(input Input) Result {
 		return p(input).Recover(input)
-	}).Wrap()
+	}).Rename("Opt")
 }
 
 // Any takes a list of input parsers and returns a parser which tries each
 // input parser until it finds a match. If it finds a match, it returns the
-// result, otherwise it returns an error result.
+// result, otherwise it returns an error result. Either way, the result's
+// furthest failure is the merger of those of every parser which was tried, so
+// a failure is reported at the furthest point any alternative reached.
 func Any(parsers ...Parser) Parser {
 	return Parser(func(input Input) Result {
-		parserNames := make([]string, len(parsers))
-		for i, p := range parsers {
+		var furthest Failure
+		for _, p := range parsers {
 			r := p(input)
+			furthest = furthest.Merge(r.Furthest)
 			if r.Err != nil {
-				parserNames[i] = r.ParserName
 				continue
 			}
+			r.Furthest = furthest
 			return r
 		}
+		if !furthest.IsValid() {
+			furthest.Pos = input.Pos()
+		}
+		return ERR(furthest, input)
+	}).Rename("Any")
+}
+
+// consumed returns a parser whose result value is the text consumed by `p`.
+func consumed(p Parser) Parser {
+	return func(input Input) Result {
+		r := p(input)
+		return r.Map(func(interface{}) interface{} {
+			return input.text[:r.Rest.pos.Offset-input.pos.Offset]
+		})
+	}
+}
 
-		return ERR(
-			fmt.Errorf(
-				"Failed to match parsers: [%s]",
-				strings.Join(parserNames, ", "),
-			),
-			input,
-		)
-	}).Wrap()
+// blockComment matches a `/* */` comment. Block comments nest, so
+// `/* a /* b */ c */` is a single comment.
+func blockComment(input Input) Result {
+	if !strings.HasPrefix(input.text, "/*") {
+		return expected(input, `"/*"`)
+	}
+	rest, depth := input.advance(2), 1
+	for depth > 0 {
+		switch {
+		case rest.text == "":
+			return ERR(
+				Failure{Pos: rest.pos, Expected: []string{`"*/"`}},
+				input,
+			)
+		case strings.HasPrefix(rest.text, "/*"):
+			rest, depth = rest.advance(2), depth+1
+		case strings.HasPrefix(rest.text, "*/"):
+			rest, depth = rest.advance(2), depth-1
+		default:
+			_, rest = rest.Cons()
+		}
+	}
+	return OK(input.text[:rest.pos.Offset-input.pos.Offset], rest)
 }
 
 func collectRunes(vs []interface{}) interface{} {
@@ -385,32 +655,45 @@ func collectRunes(vs []interface{}) interface{} {
 }
 
 var (
-	// WS is a parser in the form OneOrMore(IsClass(UnicodeClassWhiteSpace)).
-	WS = OneOrMore(IsClass(UnicodeClassWhiteSpace)).
-		MapSlice(collectRunes).
-		Rename("WS")
-
-	// CanWS is a parser in the form Repeat(IsClass(UnicodeClassWhiteSpace))
-	CanWS = Repeat(IsClass(UnicodeClassWhiteSpace)).
-		MapSlice(collectRunes).
-		Rename("CanWS")
+	// Comment is a parser that matches either a line comment (from `//` to
+	// the end of the line) or a block comment (between `/*` and `*/`, which
+	// may be nested).
+	Comment = Any(
+		consumed(Seq(StrLit("//"), Repeat(NotLit('\n')))),
+		blockComment,
+	).Rename("Comment")
+
+	// WS is a parser that matches one or more whitespace characters or
+	// comments. Its result value is the text it matched. Its failures are
+	// hidden from error messages, since whitespace is rarely what's missing.
+	WS = consumed(OneOrMore(Any(IsClass(UnicodeClassWhiteSpace), Comment))).
+		Rename("WS").
+		Token("")
+
+	// CanWS is like WS except that it also matches nothing at all.
+	CanWS = consumed(Repeat(Any(IsClass(UnicodeClassWhiteSpace), Comment))).
+		Rename("CanWS").
+		Token("")
 
 	// Digits is a parser in the form OneOrMore(IsClass(UnicodeClassDigit))
 	Digits = OneOrMore(IsClass(UnicodeClassDigit)).
 		MapSlice(collectRunes).
-		Rename("Digits")
+		Rename("Digits").
+		Token("digits")
 
 	// Letters is a parser in the form OneOrMore(IsClass(UnicodeClassLetter))
 	Letters = OneOrMore(IsClass(UnicodeClassLetter)).
 		MapSlice(collectRunes).
-		Rename("Letters")
+		Rename("Letters").
+		Token("letters")
 
 	// String is a parser that matches single-line string literals in source
 	// code
 	String = Seq(Lit('"'), Repeat(NotLit('"')), Lit('"')).
 		Get(1).
 		MapSlice(collectRunes).
-		Rename("String")
+		Rename("String").
+		Token("string")
 
 	// Int is a parser that matches decimal integers in source code
 	Int = OneOrMore(IsClass(UnicodeClassDigit)).
@@ -422,7 +705,8 @@ var (
 			}
 			return i
 		}).
-		Rename("Int")
+		Rename("Int").
+		Token("integer")
 
 	// Ident is a parser that matches identifiers in source code. Identifiers
 	// must be at least one character long. The first character must be either
@@ -441,13 +725,15 @@ var (
 			runes = append(runes, v.(rune))
 		}
 		return string(runes)
-	}).Rename("Ident")
+	}).Rename("Ident").
+		Token("identifier")
 
 	// EOF is a parser that matches the zero-value rune, which is simply this
 	// library's convention for signaling end-of-file.
 	EOF = Lit(0).Rename("EOF")
 
 	// EOS is a parser that matches the end-of-statement semi-colon, as well as
-	// leading and trailing whitespace.
-	EOS = Seq(CanWS, Lit(';'), CanWS).Rename("EOS")
+	// leading whitespace. Trailing whitespace is left to the next statement,
+	// since it may contain the statement's doc comment.
+	EOS = Seq(CanWS, Lit(';')).Rename("EOS")
 )
@@ -0,0 +1,127 @@
+package combinator
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Assoc is the associativity of an infix operator.
+type Assoc int
+
+const (
+	// AssocLeft operators group to the left: `a - b - c` is `(a - b) - c`.
+	AssocLeft Assoc = iota
+
+	// AssocRight operators group to the right: `a ^ b ^ c` is `a ^ (b ^ c)`.
+	AssocRight
+
+	// AssocNone operators don't group with operators of the same precedence:
+	// `a == b == c` doesn't parse past `a == b`.
+	AssocNone
+)
+
+// Operator is an infix operator.
+type Operator struct {
+	// Symbol is the text of the operator, e.g., "+".
+	Symbol string
+
+	// Prec is the precedence of the operator. Operators with a higher
+	// precedence bind more tightly than those with a lower precedence.
+	Prec int
+
+	// Assoc is the associativity of the operator.
+	Assoc Assoc
+}
+
+// OperatorTable is a set of infix operators.
+type OperatorTable []Operator
+
+// InfixFunc combines the values of the operands of an infix operator into a
+// single value.
+type InfixFunc func(op Operator, l, r interface{}) interface{}
+
+// Infix returns a parser for one or more operands (each parsed by `operand`)
+// separated by the operators in `table`. The operands are grouped according to
+// the precedence and associativity of the operators between them (by way of
+// precedence climbing) and combined with `combine`. If there is only one
+// operand, the result value is that operand's value.
+//
+// Operators may be surrounded by whitespace. An operator's symbol only matches
+// if it isn't immediately followed by another character which appears in one
+// of the table's symbols, so the longest symbol is always used (`<=` rather
+// than `<`) and `-` won't match the start of `->`. If an operator isn't
+// followed by an operand, the parser stops before the operator.
+func Infix(operand Parser, table OperatorTable, combine InfixFunc) Parser {
+	// try longer symbols first so they aren't shadowed by their prefixes
+	ops := append(OperatorTable(nil), table...)
+	sort.SliceStable(ops, func(i, j int) bool {
+		return len(ops[i].Symbol) > len(ops[j].Symbol)
+	})
+	var symbolRunes strings.Builder
+	for _, op := range ops {
+		symbolRunes.WriteString(op.Symbol)
+	}
+
+	// operator matches an operator whose precedence is at least `minPrec`,
+	// returning it and the input which follows it
+	operator := func(input Input, minPrec int) (Operator, Input, bool) {
+		input = CanWS(input).Rest
+		for _, op := range ops {
+			if op.Prec < minPrec || !strings.HasPrefix(input.text, op.Symbol) {
+				continue
+			}
+			next, _ := utf8.DecodeRuneInString(input.text[len(op.Symbol):])
+			if strings.ContainsRune(symbolRunes.String(), next) {
+				continue
+			}
+			return op, CanWS(input.advance(len(op.Symbol))).Rest, true
+		}
+		return Operator{}, input, false
+	}
+
+	var climb func(input Input, minPrec int) Result
+	climb = func(input Input, minPrec int) Result {
+		lhs := operand(input)
+		if lhs.Err != nil {
+			return lhs
+		}
+		value, rest, furthest := lhs.Value, lhs.Rest, lhs.Furthest
+
+		// nonAssoc is the precedence of the last AssocNone operator, which
+		// mustn't be followed by another operator of the same precedence
+		nonAssoc := -1
+		for {
+			op, next, ok := operator(rest, minPrec)
+			if !ok || op.Assoc == AssocNone && op.Prec == nonAssoc {
+				break
+			}
+			nextPrec := op.Prec + 1
+			if op.Assoc == AssocRight {
+				nextPrec = op.Prec
+			}
+			rhs := climb(next, nextPrec)
+			furthest = furthest.Merge(rhs.Furthest)
+			if rhs.Err != nil {
+				break
+			}
+			value, rest = combine(op, value, rhs.Value), rhs.Rest
+			if op.Assoc == AssocNone {
+				nonAssoc = op.Prec
+			}
+		}
+		result := OK(value, rest)
+		result.Furthest = furthest
+		return result
+	}
+
+	minPrec := 0
+	for _, op := range ops {
+		if op.Prec < minPrec {
+			minPrec = op.Prec
+		}
+	}
+	return Parser(func(input Input) Result {
+		return climb(input, minPrec)
+	}).Rename("Infix")
+}
@@ -0,0 +1,149 @@
+// Package diagnostics renders compiler errors for humans. Each diagnostic is
+// printed as a single primary message prefixed with its location in the
+// source, followed by the offending source line with the relevant region
+// underlined, and then any notes. For example:
+//
+//	foo.ga:3:9: error: Mismatched types: int != string
+//	  |
+//	3 | let z = add x "a";
+//	  |         ^^^^^^^^^
+//	  = note: ...
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/weberc2/gallium/ast"
+	"github.com/weberc2/gallium/combinator"
+	"github.com/weberc2/gallium/infer"
+)
+
+// Diagnostic is a message about a region of source code.
+type Diagnostic struct {
+	// Message is the primary message.
+	Message string
+
+	// Span is the region of source which the message is about. If it's not
+	// valid, only the file name is reported.
+	Span ast.Span
+
+	// Notes are optional additional messages.
+	Notes []string
+}
+
+// FromResult returns a diagnostic for a failed parse result. The diagnostic
+// points at the furthest position the parse reached and lists what was
+// expected there.
+func FromResult(r combinator.Result) Diagnostic {
+	if !r.Furthest.IsValid() {
+		return Diagnostic{Message: "Syntax error: " + r.Error()}
+	}
+	pos := ast.Position(r.Furthest.Pos)
+	return Diagnostic{
+		Message: "Syntax error: " + r.Furthest.Message(),
+		Span:    ast.Span{Start: pos, End: pos},
+	}
+}
+
+// FromError returns a diagnostic for an error. Errors which carry a span
+// (such as infer.TypeError) are reported at that span.
+func FromError(err error) Diagnostic {
+	switch e := err.(type) {
+	case combinator.Result:
+		return FromResult(e)
+	case infer.TypeError:
+		d := FromError(e.Err)
+		d.Span = e.Span
+		return d
+	case infer.InfiniteTypeError:
+		return Diagnostic{
+			Message: e.Error(),
+			Notes: []string{
+				"a value can't have a type which contains its own type, " +
+					"e.g., a function applied to itself",
+			},
+		}
+	default:
+		return Diagnostic{Message: err.Error()}
+	}
+}
+
+// Source is a named source file against which diagnostics are rendered.
+type Source struct {
+	Name string
+	Text string
+}
+
+// Render writes a human-readable rendering of `d` to `w`.
+func (s Source) Render(w io.Writer, d Diagnostic) error {
+	var out strings.Builder
+	if !d.Span.IsValid() {
+		fmt.Fprintf(&out, "%s: error: %s\n", s.Name, d.Message)
+		for _, note := range d.Notes {
+			fmt.Fprintf(&out, "  = note: %s\n", note)
+		}
+		_, err := io.WriteString(w, out.String())
+		return err
+	}
+
+	fmt.Fprintf(&out, "%s:%s: error: %s\n", s.Name, d.Span.Start, d.Message)
+
+	lineNo := strconv.Itoa(d.Span.Start.Line)
+	gutter := strings.Repeat(" ", len(lineNo))
+	line := s.line(d.Span.Start)
+	fmt.Fprintf(&out, "%s |\n", gutter)
+	fmt.Fprintf(&out, "%s | %s\n", lineNo, line)
+	fmt.Fprintf(&out, "%s | %s\n", gutter, underline(line, d.Span))
+	for _, note := range d.Notes {
+		fmt.Fprintf(&out, "%s = note: %s\n", gutter, note)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// line returns the line of source which contains `pos`, without its trailing
+// newline.
+func (s Source) line(pos ast.Position) string {
+	start := pos.Offset
+	if start > len(s.Text) {
+		start = len(s.Text)
+	}
+	start = strings.LastIndexByte(s.Text[:start], '\n') + 1
+	end := strings.IndexByte(s.Text[start:], '\n')
+	if end < 0 {
+		return s.Text[start:]
+	}
+	return strings.TrimSuffix(s.Text[start:start+end], "\r")
+}
+
+// underline returns a string which underlines the part of `line` covered by
+// `span`. If the span continues past the end of the line, the remainder of
+// the line is underlined. Tabs are preserved so the underline stays aligned
+// with the source.
+func underline(line string, span ast.Span) string {
+	width := utf8.RuneCountInString(line) - (span.Start.Column - 1)
+	if span.End.Line == span.Start.Line {
+		width = span.End.Column - span.Start.Column
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	var out strings.Builder
+	for i, r := range []rune(line) {
+		if i >= span.Start.Column-1 {
+			break
+		}
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
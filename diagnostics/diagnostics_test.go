@@ -0,0 +1,151 @@
+package diagnostics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+	"github.com/weberc2/gallium/combinator"
+	"github.com/weberc2/gallium/infer"
+	"github.com/weberc2/gallium/parser"
+)
+
+func TestRender(t *testing.T) {
+	pos := func(offset, line, column int) ast.Position {
+		return ast.Position{Offset: offset, Line: line, Column: column}
+	}
+
+	testCases := []struct {
+		Name       string
+		Source     string
+		Diagnostic Diagnostic
+		Wanted     string
+	}{
+		{
+			Name:       "no-span",
+			Source:     "package main",
+			Diagnostic: Diagnostic{Message: "Oops", Notes: []string{"a note"}},
+			Wanted:     "foo.ga: error: Oops\n  = note: a note\n",
+		},
+		{
+			Name:   "single-line-span",
+			Source: "package main\n\nlet z = add x \"a\";\n",
+			Diagnostic: Diagnostic{
+				Message: "Mismatched types: int != string",
+				Span:    ast.Span{Start: pos(22, 3, 9), End: pos(31, 3, 18)},
+			},
+			Wanted: "foo.ga:3:9: error: Mismatched types: int != string\n" +
+				"  |\n" +
+				"3 | let z = add x \"a\";\n" +
+				"  |         ^^^^^^^^^\n",
+		},
+		{
+			Name:   "multi-line-span",
+			Source: "let x = add\n    1;",
+			Diagnostic: Diagnostic{
+				Message: "Oops",
+				Span:    ast.Span{Start: pos(8, 1, 9), End: pos(17, 2, 6)},
+				Notes:   []string{"a note"},
+			},
+			Wanted: "foo.ga:1:9: error: Oops\n" +
+				"  |\n" +
+				"1 | let x = add\n" +
+				"  |         ^^^\n" +
+				"  = note: a note\n",
+		},
+		{
+			Name:   "point-span-w-tabs",
+			Source: "\tlet x = 1;",
+			Diagnostic: Diagnostic{
+				Message: "Oops",
+				Span:    ast.Span{Start: pos(5, 1, 6), End: pos(5, 1, 6)},
+			},
+			Wanted: "foo.ga:1:6: error: Oops\n" +
+				"  |\n" +
+				"1 | \tlet x = 1;\n" +
+				"  | \t    ^\n",
+		},
+		{
+			Name:   "wide-line-number",
+			Source: strings.Repeat("\n", 9) + "x",
+			Diagnostic: Diagnostic{
+				Message: "Oops",
+				Span:    ast.Span{Start: pos(9, 10, 1), End: pos(10, 10, 2)},
+			},
+			Wanted: "foo.ga:10:1: error: Oops\n" +
+				"   |\n" +
+				"10 | x\n" +
+				"   | ^\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var out strings.Builder
+			source := Source{Name: "foo.ga", Text: testCase.Source}
+			if err := source.Render(&out, testCase.Diagnostic); err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			if out.String() != testCase.Wanted {
+				t.Fatalf(
+					"WANTED:\n%s\nGOT:\n%s",
+					testCase.Wanted,
+					out.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestFromError(t *testing.T) {
+	text := "package main\n\nlet z = add x \"a\";\n"
+	result := parser.File(combinator.NewInput(text))
+	if result.Err != nil {
+		t.Fatal("Unexpected error:", result)
+	}
+	env := infer.Environment{
+		"x": infer.Mono(ast.Primitive("int")),
+		"add": infer.Mono(ast.FuncSpec{
+			Arg: ast.Primitive("int"),
+			Ret: ast.FuncSpec{
+				Arg: ast.Primitive("int"),
+				Ret: ast.Primitive("int"),
+			},
+		}),
+	}
+	_, err := infer.File(env, result.Value.(ast.File))
+	if err == nil {
+		t.Fatal("Wanted an error; got none")
+	}
+
+	d := FromError(err)
+	if wanted := "3:9"; d.Span.String() != wanted {
+		t.Fatalf("Wanted error at %s; got %s (%v)", wanted, d.Span, err)
+	}
+	if wanted := "Mismatched types"; !strings.HasPrefix(d.Message, wanted) {
+		t.Fatalf("Wanted message %#v; got %#v", wanted, d.Message)
+	}
+
+	if d := FromError(errors.New("Oops")); d.Span.IsValid() {
+		t.Fatalf("Wanted no span; got %s", d.Span)
+	}
+}
+
+func TestFromResult(t *testing.T) {
+	text := "package main\n\nlet = 2;\n"
+	result := parser.File(combinator.NewInput(text))
+	if result.Err == nil {
+		t.Fatal("Wanted an error; got none")
+	}
+	d := FromResult(result)
+	if !d.Span.IsValid() {
+		t.Fatalf("Wanted a span; got none (%v)", result)
+	}
+	if wanted := "3:5"; d.Span.String() != wanted {
+		t.Fatalf("Wanted error at %s; got %s (%v)", wanted, d.Span, result)
+	}
+	if !strings.Contains(d.Message, "expected identifier") {
+		t.Fatalf("Wanted an expected identifier; got %#v", d.Message)
+	}
+}
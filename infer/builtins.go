@@ -0,0 +1,31 @@
+package infer
+
+import "github.com/weberc2/gallium/ast"
+
+// Operators returns an environment which binds each infix operator (e.g.,
+// "+") to its type. The parser desugars `a + b` into the call `(+ a) b`, so
+// the operators are typed like any other function. `==` and `!=` accept
+// operands of any (single) type; the other comparisons and the arithmetic
+// operators are defined only for ints.
+func Operators() Environment {
+	binary := func(arg, ret ast.Type) ast.Type {
+		return ast.FuncSpec{Arg: arg, Ret: ast.FuncSpec{Arg: arg, Ret: ret}}
+	}
+	integer, boolean := ast.Primitive("int"), ast.Primitive("bool")
+	a := ast.TypeVar("a")
+	return Environment{
+		"||": Mono(binary(boolean, boolean)),
+		"&&": Mono(binary(boolean, boolean)),
+		"==": Scheme{Vars: []ast.TypeVar{a}, Type: binary(a, boolean)},
+		"!=": Scheme{Vars: []ast.TypeVar{a}, Type: binary(a, boolean)},
+		"<":  Mono(binary(integer, boolean)),
+		">":  Mono(binary(integer, boolean)),
+		"<=": Mono(binary(integer, boolean)),
+		">=": Mono(binary(integer, boolean)),
+		"+":  Mono(binary(integer, integer)),
+		"-":  Mono(binary(integer, integer)),
+		"*":  Mono(binary(integer, integer)),
+		"/":  Mono(binary(integer, integer)),
+		"%":  Mono(binary(integer, integer)),
+	}
+}
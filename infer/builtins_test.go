@@ -0,0 +1,87 @@
+package infer
+
+import (
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestOperators(t *testing.T) {
+	binary := func(op string, l, r ast.Expr) ast.Expr {
+		return ast.Expr{Node: ast.Call{
+			Fn: ast.Expr{Node: ast.Call{
+				Fn:  ast.Expr{Node: ast.Ident(op)},
+				Arg: l,
+			}},
+			Arg: r,
+		}}
+	}
+	intLit := func(i int) ast.Expr { return ast.Expr{Node: ast.IntLit(i)} }
+	stringLit := func(s string) ast.Expr {
+		return ast.Expr{Node: ast.StringLit(s)}
+	}
+
+	testCases := []struct {
+		Name      string
+		Input     ast.Expr
+		Wanted    ast.Type
+		WantedErr bool
+	}{
+		{
+			Name:   "arithmetic",
+			Input:  binary("+", intLit(1), binary("*", intLit(2), intLit(3))),
+			Wanted: ast.Primitive("int"),
+		},
+		{
+			Name: "comparison-and-logic",
+			Input: binary(
+				"&&",
+				binary("<", intLit(1), intLit(2)),
+				binary("!=", intLit(3), intLit(4)),
+			),
+			Wanted: ast.Primitive("bool"),
+		},
+		{
+			Name:   "equality-polymorphic",
+			Input:  binary("==", stringLit("a"), stringLit("b")),
+			Wanted: ast.Primitive("bool"),
+		},
+		{
+			Name:      "equality-mismatched",
+			Input:     binary("==", intLit(1), stringLit("a")),
+			WantedErr: true,
+		},
+		{
+			Name:      "arithmetic-on-string",
+			Input:     binary("+", intLit(1), stringLit("a")),
+			WantedErr: true,
+		},
+		{
+			Name: "arithmetic-on-bool",
+			Input: binary(
+				"-",
+				binary("<", intLit(1), intLit(2)),
+				intLit(1),
+			),
+			WantedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			got, err := Infer(Operators(), testCase.Input)
+			if err != nil {
+				if !testCase.WantedErr {
+					t.Fatal("Unexpected error:", err)
+				}
+				return
+			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got type %v", got.Type)
+			}
+			if !got.Type.EqualType(testCase.Wanted) {
+				t.Fatalf("Wanted type %v; got %v", testCase.Wanted, got.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package infer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// `==` and `!=` are typed `'a -> 'a -> bool`, but they're restricted to
+// equality types, i.e., types which don't contain function types, since
+// functions can't be compared (nor can Go values which contain them). A
+// polymorphic binding which compares values of one of its type variables,
+// either directly or by passing them to another such binding (e.g., `let
+// same = a -> b -> a == b; let same2 = x -> y -> same x y;`), may only be
+// instantiated with an equality type for that variable.
+
+// equalityBinding is a let binding whose type variables may be restricted to
+// equality types.
+type equalityBinding struct {
+	typ ast.Type
+
+	// vars holds the type variables of `typ` which are restricted to
+	// equality types
+	vars []ast.TypeVar
+}
+
+// instances returns the types with which the binding's restricted type
+// variables are instantiated for a reference to it of type `t`.
+func (b *equalityBinding) instances(t ast.Type) []ast.Type {
+	if len(b.vars) < 1 {
+		return nil
+	}
+
+	// the binding's type variables are renamed apart from those of `t`,
+	// which may be named the same
+	var fresh []Substitution
+	for i, tv := range FreeTypeVars(b.typ) {
+		fresh = append(fresh, Substitution{
+			Var:  tv,
+			Type: ast.TypeVar("_" + strconv.Itoa(i)),
+		})
+	}
+	subs, err := UnifyOne(Apply(fresh, b.typ), t)
+	if err != nil {
+		panic(fmt.Sprintf(
+			"Reference of type %v to a binding of type %v",
+			t,
+			b.typ,
+		))
+	}
+	out := make([]ast.Type, len(b.vars))
+	for i, tv := range b.vars {
+		out[i] = Apply(subs, Apply(fresh, tv))
+	}
+	return out
+}
+
+// equality checks the comparisons of an annotated file.
+type equality struct {
+	// changed is set when a type variable is restricted, so the file's
+	// bindings are checked until no more variables are restricted, since
+	// a binding may refer to later (or mutually recursive) bindings
+	changed bool
+}
+
+// Comparables returns the type variables of each top-level let binding's
+// type in the annotated `stmts` which are restricted to equality types. It
+// returns an error if values of a type which isn't an equality type are
+// compared.
+func Comparables(stmts []ast.Stmt) (map[ast.Ident][]ast.TypeVar, error) {
+	top := map[ast.Ident]*equalityBinding{}
+	for _, stmt := range stmts {
+		if letDecl, ok := stmt.(ast.LetDecl); ok {
+			top[letDecl.Ident] = &equalityBinding{typ: letDecl.Binding.Type}
+		}
+	}
+
+	for e := (equality{changed: true}); e.changed; {
+		e.changed = false
+		for _, stmt := range stmts {
+			scope := make(map[ast.Ident]*equalityBinding, len(top))
+			for ident, b := range top {
+				scope[ident] = b
+			}
+			var err error
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				lets := []*equalityBinding{top[x.Ident]}
+				err = e.check(x.Binding, scope, lets)
+			case ast.Expr:
+				err = e.check(x, scope, nil)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make(map[ast.Ident][]ast.TypeVar, len(top))
+	for ident, b := range top {
+		if len(b.vars) > 0 {
+			out[ident] = b.vars
+		}
+	}
+	return out, nil
+}
+
+// check checks the comparisons in `expr` (including those made by passing
+// values to bindings in `scope` which compare them). The type variables of
+// compared values are restricted in each of the enclosing let bindings in
+// `lets` whose types have them. `scope` maps the let-bound identifiers in
+// scope to their bindings and other local variables to nil.
+func (e *equality) check(
+	expr ast.Expr,
+	scope map[ast.Ident]*equalityBinding,
+	lets []*equalityBinding,
+) error {
+	switch node := expr.Node.(type) {
+	case ast.IntLit, ast.StringLit:
+		return nil
+	case ast.Ident:
+		var compared []ast.Type
+		if b, found := scope[node]; found {
+			if b != nil {
+				compared = b.instances(expr.Type)
+			}
+		} else if fs, ok := expr.Type.(ast.FuncSpec); ok &&
+			(node == "==" || node == "!=") {
+			compared = []ast.Type{fs.Arg}
+		}
+		for _, t := range compared {
+			if !isEqualityType(t, map[string]bool{}) {
+				return TypeError{
+					Span: expr.Span,
+					Err: fmt.Errorf(
+						"Can't compare values of type %v, which contains "+
+							"a function",
+						t,
+					),
+				}
+			}
+			for _, tv := range FreeTypeVars(t) {
+				for _, b := range lets {
+					if ContainsTypeVar(FreeTypeVars(b.typ), tv) &&
+						!ContainsTypeVar(b.vars, tv) {
+						b.vars = append(b.vars, tv)
+						e.changed = true
+					}
+				}
+			}
+		}
+		return nil
+	case ast.TupleLit:
+		for _, expr := range node {
+			if err := e.check(expr, scope, lets); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.Block:
+		inner := shadowEquality(scope)
+		for _, stmt := range node.Stmts {
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				b := &equalityBinding{typ: x.Binding.Type}
+				enclosing := append(append([]*equalityBinding(nil), lets...), b)
+				if err := e.check(x.Binding, inner, enclosing); err != nil {
+					return err
+				}
+				inner[x.Ident] = b
+			case ast.Expr:
+				if err := e.check(x, inner, lets); err != nil {
+					return err
+				}
+			}
+		}
+		return e.check(node.Expr, inner, lets)
+	case ast.FuncLit:
+		inner := shadowEquality(scope, node.Arg)
+		return e.check(node.Body, inner, lets)
+	case ast.Call:
+		if err := e.check(node.Fn, scope, lets); err != nil {
+			return err
+		}
+		return e.check(node.Arg, scope, lets)
+	case ast.If:
+		for _, expr := range []ast.Expr{node.Cond, node.Then, node.Else} {
+			if err := e.check(expr, scope, lets); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.Match:
+		if err := e.check(node.Expr, scope, lets); err != nil {
+			return err
+		}
+		for _, c := range node.Cases {
+			inner := shadowEquality(scope, c.Pattern.Vars()...)
+			if err := e.check(c.Body, inner, lets); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.RecordLit:
+		for _, f := range node {
+			if err := e.check(f.Value, scope, lets); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.Project:
+		return e.check(node.Record, scope, lets)
+	case ast.RecordUpdate:
+		if err := e.check(node.Record, scope, lets); err != nil {
+			return err
+		}
+		for _, f := range node.Fields {
+			if err := e.check(f.Value, scope, lets); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		panic(fmt.Sprintf(
+			"check() not implemented for %# v",
+			pretty.Formatter(expr.Node),
+		))
+	}
+}
+
+// shadowEquality returns a copy of `scope` in which `idents` are local
+// variables which aren't let-bound.
+func shadowEquality(
+	scope map[ast.Ident]*equalityBinding,
+	idents ...ast.Ident,
+) map[ast.Ident]*equalityBinding {
+	out := make(map[ast.Ident]*equalityBinding, len(scope)+len(idents))
+	for ident, b := range scope {
+		out[ident] = b
+	}
+	for _, ident := range idents {
+		out[ident] = nil
+	}
+	return out
+}
+
+// isEqualityType returns true if `t` doesn't contain a function type. The
+// variants of the sum types in `t` are checked too, except for those of the
+// sum types named in `seen`, which are already being checked.
+func isEqualityType(t ast.Type, seen map[string]bool) bool {
+	switch x := t.(type) {
+	case ast.FuncSpec:
+		return false
+	case ast.TupleSpec:
+		for _, t := range x {
+			if !isEqualityType(t, seen) {
+				return false
+			}
+		}
+	case ast.RecordSpec:
+		for _, f := range x.Fields {
+			if !isEqualityType(f.Type, seen) {
+				return false
+			}
+		}
+	case ast.TypeRef:
+		for _, arg := range x.Args {
+			if !isEqualityType(arg, seen) {
+				return false
+			}
+		}
+		if seen[x.Name] {
+			return true
+		}
+		seen[x.Name] = true
+		for _, v := range x.Variants() {
+			if v.Type != nil && !isEqualityType(v.Type, seen) {
+				return false
+			}
+		}
+	}
+	return true
+}
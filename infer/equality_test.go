@@ -0,0 +1,179 @@
+package infer
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestEquality(t *testing.T) {
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	call := func(fn ast.Expr, args ...ast.Expr) ast.Expr {
+		for _, arg := range args {
+			fn = ast.Expr{Node: ast.Call{Fn: fn, Arg: arg}}
+		}
+		return fn
+	}
+	funcLit := func(arg string, body ast.Expr) ast.Expr {
+		return ast.Expr{Node: ast.FuncLit{Arg: ast.Ident(arg), Body: body}}
+	}
+	let := func(name string, binding ast.Expr) ast.Stmt {
+		return ast.LetDecl{Ident: ast.Ident(name), Binding: binding}
+	}
+	intLit := ast.Expr{Node: ast.IntLit(1)}
+	id := let("id", funcLit("x", ident("x")))
+	same := let("same", funcLit("a", funcLit("b", call(
+		ident("=="),
+		ident("a"),
+		ident("b"),
+	))))
+	same2 := let("same2", funcLit("x", funcLit("y", call(
+		ident("same"),
+		ident("x"),
+		ident("y"),
+	))))
+
+	testCases := []struct {
+		Name  string
+		Stmts []ast.Stmt
+		// Comparable holds the bindings with type variables restricted to
+		// equality types
+		Comparable []ast.Ident
+		WantedErr  string
+	}{
+		{
+			Name:  "ints",
+			Stmts: []ast.Stmt{let("a", call(ident("=="), intLit, intLit))},
+		},
+		{
+			Name: "functions",
+			Stmts: []ast.Stmt{
+				id,
+				let("a", call(ident("!="), ident("id"), ident("id"))),
+			},
+			WantedErr: "Can't compare values of type",
+		},
+		{
+			Name: "tuples-of-functions",
+			Stmts: []ast.Stmt{
+				id,
+				let("a", call(
+					ident("=="),
+					ast.Expr{Node: ast.TupleLit{intLit, ident("id")}},
+					ast.Expr{Node: ast.TupleLit{intLit, ident("id")}},
+				)),
+			},
+			WantedErr: "Can't compare values of type",
+		},
+		{
+			Name: "sum-type-of-functions",
+			Stmts: []ast.Stmt{
+				ast.TypeDecl{Name: "Box", Type: ast.SumSpec{{
+					Name: "Box",
+					Type: ast.FuncSpec{
+						Arg: ast.TypeRef{Name: "int"},
+						Ret: ast.TypeRef{Name: "int"},
+					},
+				}}},
+				id,
+				let("a", call(
+					ident("=="),
+					call(ident("Box"), ident("id")),
+					call(ident("Box"), ident("id")),
+				)),
+			},
+			WantedErr: "Can't compare values of type Box",
+		},
+		{
+			Name: "polymorphic",
+			Stmts: []ast.Stmt{
+				same,
+				let("a", call(ident("same"), intLit, intLit)),
+			},
+			Comparable: []ast.Ident{"same"},
+		},
+		{
+			Name: "polymorphic-at-functions",
+			Stmts: []ast.Stmt{
+				id,
+				same,
+				let("a", call(ident("same"), ident("id"), ident("id"))),
+			},
+			WantedErr: "Can't compare values of type",
+		},
+		{
+			Name: "transitive",
+			Stmts: []ast.Stmt{
+				same2,
+				same,
+				let("a", call(ident("same2"), intLit, intLit)),
+			},
+			Comparable: []ast.Ident{"same", "same2"},
+		},
+		{
+			Name: "transitive-at-functions",
+			Stmts: []ast.Stmt{
+				id,
+				same2,
+				same,
+				let("a", call(ident("same2"), ident("id"), ident("id"))),
+			},
+			WantedErr: "Can't compare values of type",
+		},
+		{
+			Name: "shadowed",
+			Stmts: []ast.Stmt{
+				same,
+				let("f", funcLit("same", call(
+					ident("same"),
+					funcLit("x", ident("x")),
+				))),
+			},
+			Comparable: []ast.Ident{"same"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{Package: "main", Stmts: testCase.Stmts}
+			got, err := File(Operators(), input)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatal("Wanted an error; got none")
+			}
+
+			vars, err := Comparables(got.Stmts)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			var idents []string
+			for ident := range vars {
+				idents = append(idents, string(ident))
+			}
+			sort.Strings(idents)
+			var wanted []string
+			for _, ident := range testCase.Comparable {
+				wanted = append(wanted, string(ident))
+			}
+			if strings.Join(idents, ",") != strings.Join(wanted, ",") {
+				t.Fatalf("Wanted %v to be comparable; got %v", wanted, idents)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package infer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// File infers the types of every top-level binding in `f` and returns the
+// annotated file. Bindings may reference one another regardless of the order
+// in which they appear in the source. Bindings which reference each other
+// (directly or indirectly) are inferred together as a group: within the group
+// each binding is monomorphic, and the group's bindings are generalized once
+// the whole group has been inferred. Top-level expression statements are
+// inferred last, in an environment containing every top-level binding.
+//
+// The constructors of the sum types declared in `f` are available to every
+// binding. The type refs of the type decls and annotations in `f` are
+// kind-checked (see resolver.checkKind) and resolved against the declared
+// types (see resolver.resolveType) before any binding is inferred, so aliases
+// are expanded and it's an error to refer to an undeclared type, to pass a
+// type the wrong number of arguments or to use an undeclared type variable in
+// a type declaration. Values may only be compared if their types are
+// equality types (see Comparables). `main` is the program's entry point, so
+// it mustn't be a function.
+func File(env Environment, f ast.File) (ast.File, error) {
+	decls := map[string]*ast.TypeDecl{}
+	var order []*ast.TypeDecl
+	for _, stmt := range f.Stmts {
+		if typeDecl, ok := stmt.(ast.TypeDecl); ok {
+			if _, found := decls[typeDecl.Name]; found {
+				return ast.File{}, TypeError{
+					Span: typeDecl.Span,
+					Err: fmt.Errorf(
+						"Duplicate type definition: '%s'",
+						typeDecl.Name,
+					),
+				}
+			}
+			decl := typeDecl
+			decls[decl.Name] = &decl
+			order = append(order, &decl)
+		}
+	}
+	supply := NewSupply(env)
+	r := newResolver(decls, supply)
+	for _, decl := range order {
+		if err := r.checkDecl(decl); err != nil {
+			return ast.File{}, err
+		}
+	}
+	ctors := Environment{}
+	for _, decl := range order {
+		if err := r.resolveDecl(decl); err != nil {
+			return ast.File{}, err
+		}
+		ss, ok := decl.Type.(ast.SumSpec)
+		if !ok {
+			continue
+		}
+		declCtors := Constructors(decl)
+		for _, v := range ss {
+			ident := ast.Ident(v.Name)
+			if _, found := ctors[ident]; found {
+				return ast.File{}, TypeError{
+					Span: decl.Span,
+					Err:  fmt.Errorf("Duplicate constructor: '%s'", ident),
+				}
+			}
+			ctors[ident] = declCtors[ident]
+			env = env.Add(ident, declCtors[ident])
+		}
+	}
+
+	// the annotations are resolved (and kind-checked) before anything is
+	// inferred
+	var lets []ast.LetDecl
+	var rigids []map[ast.TypeVar]rigidVar
+	indices := map[ast.Ident]int{}
+	exprs := map[int]ast.Expr{}
+	exprRigids := map[int]map[ast.TypeVar]rigidVar{}
+	for i, stmt := range f.Stmts {
+		if expr, ok := stmt.(ast.Expr); ok {
+			r := r.binding()
+			resolved, err := r.resolveAnnotations(expr)
+			if err != nil {
+				return ast.File{}, err
+			}
+			exprs[i] = resolved
+			exprRigids[i] = r.rigid
+		}
+		if letDecl, ok := stmt.(ast.LetDecl); ok {
+			_, isCtor := ctors[letDecl.Ident]
+			if _, found := indices[letDecl.Ident]; found || isCtor {
+				return ast.File{}, TypeError{
+					Span: letDecl.Span,
+					Err: fmt.Errorf(
+						"Duplicate definition: '%s'",
+						letDecl.Ident,
+					),
+				}
+			}
+			r := r.binding()
+			resolved, err := r.resolveLetDecl(letDecl)
+			if err != nil {
+				return ast.File{}, err
+			}
+			indices[letDecl.Ident] = len(lets)
+			lets = append(lets, resolved)
+			rigids = append(rigids, r.rigid)
+		}
+	}
+
+	deps := make([][]int, len(lets))
+	for i, letDecl := range lets {
+		for _, ident := range FreeIdents(letDecl.Binding) {
+			if j, found := indices[ident]; found {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	bindings := make([]ast.Expr, len(lets))
+	for _, group := range components(deps) {
+		if err := checkRecursion(lets, deps, group); err != nil {
+			return ast.File{}, err
+		}
+		groupEnv := env.Copy()
+		vars := make([]ast.TypeVar, len(group))
+		for i, j := range group {
+			vars[i] = supply.Fresh()
+			groupEnv[lets[j].Ident] = Mono(vars[i])
+		}
+
+		var constraints, annotations []Constraint
+		for i, j := range group {
+			annotated, err := AnnotateExpr(lets[j].Binding, groupEnv, supply)
+			if err != nil {
+				return ast.File{}, err
+			}
+			cs, err := CollectExpr(annotated)
+			if err != nil {
+				return ast.File{}, err
+			}
+			constraints = append(constraints, cs...)
+			constraints = append(
+				constraints,
+				Constraint{vars[i], annotated.Type, lets[j].Span},
+			)
+			if lets[j].Type != nil {
+				annotations = append(
+					annotations,
+					Constraint{vars[i], lets[j].Type, lets[j].Span},
+				)
+			}
+			bindings[j] = annotated
+		}
+
+		// the annotations are appended so they're solved first (see Unify)
+		subs, err := Unify(append(constraints, annotations...))
+		if err != nil {
+			return ast.File{}, err
+		}
+		for _, j := range group {
+			if err := checkRigid(subs, rigids[j]); err != nil {
+				return ast.File{}, err
+			}
+		}
+
+		// Generalize against the environment without the group's own
+		// bindings so the group's type variables can be quantified.
+		groupSchemes := make([]Scheme, len(group))
+		for i, j := range group {
+			bindings[j] = ApplyExpr(subs, bindings[j])
+			if err := CheckMatches(bindings[j]); err != nil {
+				return ast.File{}, err
+			}
+			groupSchemes[i] = Generalize(env, Apply(subs, vars[i]))
+		}
+		for i, j := range group {
+			env = env.Add(lets[j].Ident, groupSchemes[i])
+		}
+	}
+
+	stmts := make([]ast.Stmt, len(f.Stmts))
+	for i, stmt := range f.Stmts {
+		switch x := stmt.(type) {
+		case ast.LetDecl:
+			x = lets[indices[x.Ident]]
+			x.Binding = bindings[indices[x.Ident]]
+			if _, ok := x.Binding.Type.(ast.FuncSpec); ok && x.Ident == "main" {
+				return ast.File{}, TypeError{
+					Span: x.Span,
+					Err: fmt.Errorf(
+						"'main' is evaluated when the program starts, so it "+
+							"can't be a function: %v",
+						x.Binding.Type,
+					),
+				}
+			}
+			stmts[i] = x
+		case ast.TypeDecl:
+			stmts[i] = *decls[x.Name]
+		case ast.Expr:
+			expr, err := infer(env, exprs[i], exprRigids[i], supply)
+			if err != nil {
+				return ast.File{}, err
+			}
+			stmts[i] = expr
+		default:
+			stmts[i] = stmt
+		}
+	}
+	if _, err := Comparables(stmts); err != nil {
+		return ast.File{}, err
+	}
+	return ast.File{Package: f.Package, Stmts: stmts, Span: f.Span}, nil
+}
+
+// checkRecursion returns an error if the group of bindings `group` is
+// recursive (i.e., it has more than one binding or its binding refers to
+// itself) and any of its bindings isn't a function literal. Only functions
+// may be recursive: a recursive value has no value to begin with.
+func checkRecursion(lets []ast.LetDecl, deps [][]int, group []int) error {
+	if len(group) < 2 && !containsInt(deps[group[0]], group[0]) {
+		return nil
+	}
+	for _, i := range group {
+		if _, ok := lets[i].Binding.Node.(ast.FuncLit); !ok {
+			return TypeError{
+				Span: lets[i].Span,
+				Err: fmt.Errorf(
+					"Recursive definition of '%s', which isn't a function",
+					lets[i].Ident,
+				),
+			}
+		}
+	}
+	return nil
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// FreeIdents returns the identifiers referenced by `expr` which aren't bound
+// within `expr`, in order of their first occurrence.
+func FreeIdents(expr ast.Expr) []ast.Ident {
+	var out []ast.Ident
+	var visit func(expr ast.Expr, bound map[ast.Ident]bool)
+	visit = func(expr ast.Expr, bound map[ast.Ident]bool) {
+		switch node := expr.Node.(type) {
+		case nil, ast.IntLit, ast.StringLit:
+		case ast.Ident:
+			if bound[node] {
+				return
+			}
+			for _, ident := range out {
+				if ident == node {
+					return
+				}
+			}
+			out = append(out, node)
+		case ast.TupleLit:
+			for _, expr := range node {
+				visit(expr, bound)
+			}
+		case ast.Block:
+			inner := copyBound(bound)
+			for _, stmt := range node.Stmts {
+				switch x := stmt.(type) {
+				case ast.LetDecl:
+					visit(x.Binding, inner)
+					inner[x.Ident] = true
+				case ast.Expr:
+					visit(x, inner)
+				}
+			}
+			visit(node.Expr, inner)
+		case ast.FuncLit:
+			inner := copyBound(bound)
+			inner[node.Arg] = true
+			visit(node.Body, inner)
+		case ast.Call:
+			visit(node.Fn, bound)
+			visit(node.Arg, bound)
+		case ast.If:
+			visit(node.Cond, bound)
+			visit(node.Then, bound)
+			visit(node.Else, bound)
+		case ast.RecordLit:
+			for _, f := range node {
+				visit(f.Value, bound)
+			}
+		case ast.Project:
+			visit(node.Record, bound)
+		case ast.RecordUpdate:
+			visit(node.Record, bound)
+			for _, f := range node.Fields {
+				visit(f.Value, bound)
+			}
+		case ast.Match:
+			visit(node.Expr, bound)
+			for _, c := range node.Cases {
+				inner := copyBound(bound)
+				for _, ident := range c.Pattern.Vars() {
+					inner[ident] = true
+				}
+				visit(c.Body, inner)
+			}
+		default:
+			panic(fmt.Sprintf(
+				"FreeIdents() not implemented for %# v",
+				pretty.Formatter(expr.Node),
+			))
+		}
+	}
+	visit(expr, map[ast.Ident]bool{})
+	return out
+}
+
+func copyBound(bound map[ast.Ident]bool) map[ast.Ident]bool {
+	out := make(map[ast.Ident]bool, len(bound)+1)
+	for ident := range bound {
+		out[ident] = true
+	}
+	return out
+}
+
+// components returns the strongly connected components of the graph whose
+// edges are described by `deps` (node `i` has an edge to each node in
+// `deps[i]`). Components are returned in dependency order, i.e., each
+// component comes after every component it has an edge to. This is Tarjan's
+// algorithm.
+func components(deps [][]int) [][]int {
+	var (
+		out     [][]int
+		stack   []int
+		next    int
+		index   = make([]int, len(deps))
+		lowlink = make([]int, len(deps))
+		onStack = make([]bool, len(deps))
+		visited = make([]bool, len(deps))
+	)
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v], lowlink[v] = next, next
+		next++
+		visited[v] = true
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range deps[v] {
+			if !visited[w] {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			var component []int
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			// keep each group in source order
+			sort.Ints(component)
+			out = append(out, component)
+		}
+	}
+
+	for v := range deps {
+		if !visited[v] {
+			connect(v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,498 @@
+package infer
+
+import (
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestFileSumTypes(t *testing.T) {
+	intRef := ast.TypeRef{Name: "int"}
+	option := ast.TypeDecl{
+		Name: "Option",
+		Type: ast.SumSpec{
+			{Name: "None"},
+			{Name: "Some", Type: ast.TypeRef{Name: "a"}},
+		},
+		Args: []ast.TypeVar{"a"},
+	}
+	shape := ast.TypeDecl{
+		Name: "Shape",
+		Type: ast.SumSpec{
+			{Name: "Circle", Type: intRef},
+			{Name: "Rect", Type: ast.TupleSpec{intRef, intRef}},
+		},
+	}
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	call := func(fn string, arg ast.Expr) ast.Expr {
+		return ast.Expr{Node: ast.Call{Fn: ident(fn), Arg: arg}}
+	}
+	intLit := ast.Expr{Node: ast.IntLit(1)}
+	stringLit := ast.Expr{Node: ast.StringLit("a")}
+	optionOf := func(t ast.Type) ast.Type {
+		return ast.TypeRef{Name: "Option", Args: []ast.Type{t}}
+	}
+
+	testCases := []struct {
+		Name      string
+		Stmts     []ast.Stmt
+		Wanted    ast.Type
+		WantedErr bool
+	}{
+		{
+			Name: "constructor",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.LetDecl{Ident: "x", Binding: call("Circle", intLit)},
+			},
+			Wanted: ast.TypeRef{Name: "Shape"},
+		},
+		{
+			Name: "constructor-tuple",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.LetDecl{
+					Ident: "x",
+					Binding: call("Rect", ast.Expr{
+						Node: ast.TupleLit{intLit, intLit},
+					}),
+				},
+			},
+			Wanted: ast.TypeRef{Name: "Shape"},
+		},
+		{
+			Name: "constructor-unapplied",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.LetDecl{Ident: "x", Binding: ident("Circle")},
+			},
+			Wanted: ast.FuncSpec{
+				Arg: ast.Primitive("int"),
+				Ret: ast.TypeRef{Name: "Shape"},
+			},
+		},
+		{
+			Name: "constructor-mismatch",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.LetDecl{Ident: "x", Binding: call("Circle", stringLit)},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "constructor-polymorphic",
+			Stmts: []ast.Stmt{
+				option,
+				ast.LetDecl{
+					Ident: "x",
+					Binding: ast.Expr{Node: ast.TupleLit{
+						call("Some", intLit),
+						call("Some", stringLit),
+					}},
+				},
+			},
+			Wanted: ast.TupleSpec{
+				optionOf(ast.Primitive("int")),
+				optionOf(ast.Primitive("string")),
+			},
+		},
+		{
+			Name: "constructor-nullary",
+			Stmts: []ast.Stmt{
+				option,
+				ast.LetDecl{Ident: "x", Binding: ident("None")},
+			},
+			Wanted: optionOf(ast.TypeVar("t1")),
+		},
+		{
+			Name: "constructor-forward-reference",
+			Stmts: []ast.Stmt{
+				ast.LetDecl{Ident: "x", Binding: call("Circle", intLit)},
+				shape,
+			},
+			Wanted: ast.TypeRef{Name: "Shape"},
+		},
+		{
+			Name:      "duplicate-type",
+			Stmts:     []ast.Stmt{shape, shape},
+			WantedErr: true,
+		},
+		{
+			Name: "duplicate-constructor",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.TypeDecl{
+					Name: "Circle",
+					Type: ast.SumSpec{{Name: "Circle"}},
+				},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "let-shadows-constructor",
+			Stmts: []ast.Stmt{
+				shape,
+				ast.LetDecl{Ident: "Circle", Binding: intLit},
+			},
+			WantedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{Package: "main", Stmts: testCase.Stmts}
+			got, err := File(Environment{}, input)
+			if err != nil {
+				if !testCase.WantedErr {
+					t.Fatal("Unexpected error:", err)
+				}
+				return
+			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
+
+			for _, stmt := range got.Stmts {
+				letDecl, ok := stmt.(ast.LetDecl)
+				if !ok {
+					continue
+				}
+				if !letDecl.Binding.Type.EqualType(testCase.Wanted) {
+					t.Fatalf(
+						"WANTED:\n%# v\n\nGOT:\n%# v\n",
+						pretty.Formatter(testCase.Wanted),
+						pretty.Formatter(letDecl.Binding.Type),
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	env := Environment{
+		ast.Ident("add"): Mono(ast.FuncSpec{
+			Arg: ast.Primitive("int"),
+			Ret: ast.FuncSpec{
+				Arg: ast.Primitive("int"),
+				Ret: ast.Primitive("int"),
+			},
+		}),
+	}
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	call := func(fn ast.Expr, args ...ast.Expr) ast.Expr {
+		for _, arg := range args {
+			fn = ast.Expr{Node: ast.Call{Fn: fn, Arg: arg}}
+		}
+		return fn
+	}
+	funcLit := func(arg string, body ast.Expr) ast.Expr {
+		return ast.Expr{Node: ast.FuncLit{Arg: ast.Ident(arg), Body: body}}
+	}
+	intLit := func(i int) ast.Expr { return ast.Expr{Node: ast.IntLit(i)} }
+	addOne := func(expr ast.Expr) ast.Expr {
+		return call(ident("add"), expr, intLit(1))
+	}
+	fromInt := func(ret ast.Type) ast.Type {
+		return ast.FuncSpec{Arg: ast.Primitive("int"), Ret: ret}
+	}
+	intRef := ast.TypeRef{Name: "int"}
+	varA, varT0 := ast.TypeVar("a"), ast.TypeVar("t0")
+
+	testCases := []struct {
+		Name string
+		Let  []ast.LetDecl
+		// Wanted is the wanted type of each let decl's binding
+		Wanted    []ast.Type
+		WantedErr bool
+	}{
+		{
+			Name: "forward-reference",
+			Let: []ast.LetDecl{
+				{Ident: "x", Binding: addOne(ident("y"))},
+				{Ident: "y", Binding: intLit(2)},
+			},
+			Wanted: []ast.Type{ast.Primitive("int"), ast.Primitive("int")},
+		},
+		{
+			// let f = x -> g x; let g = y -> add y 1;
+			Name: "forward-reference-func",
+			Let: []ast.LetDecl{
+				{
+					Ident:   "f",
+					Binding: funcLit("x", call(ident("g"), ident("x"))),
+				},
+				{Ident: "g", Binding: funcLit("y", addOne(ident("y")))},
+			},
+			Wanted: []ast.Type{
+				fromInt(ast.Primitive("int")),
+				fromInt(ast.Primitive("int")),
+			},
+		},
+		{
+			// let f = x -> f (add x 1);
+			Name: "recursion",
+			Let: []ast.LetDecl{{
+				Ident:   "f",
+				Binding: funcLit("x", call(ident("f"), addOne(ident("x")))),
+			}},
+			Wanted: []ast.Type{fromInt(ast.TypeVar("t5"))},
+		},
+		{
+			// let f = x -> g (add x 1); let g = y -> f y;
+			Name: "mutual-recursion",
+			Let: []ast.LetDecl{
+				{
+					Ident:   "f",
+					Binding: funcLit("x", call(ident("g"), addOne(ident("x")))),
+				},
+				{
+					Ident:   "g",
+					Binding: funcLit("y", call(ident("f"), ident("y"))),
+				},
+			},
+			Wanted: []ast.Type{
+				fromInt(ast.TypeVar("t6")),
+				fromInt(ast.TypeVar("t6")),
+			},
+		},
+		{
+			// let pair = (id 1, id "a"); let id = x -> x;
+			Name: "generalized-after-group",
+			Let: []ast.LetDecl{
+				{
+					Ident: "pair",
+					Binding: ast.Expr{Node: ast.TupleLit{
+						call(ident("id"), intLit(1)),
+						call(ident("id"), ast.Expr{Node: ast.StringLit("a")}),
+					}},
+				},
+				{Ident: "id", Binding: funcLit("x", ident("x"))},
+			},
+			Wanted: []ast.Type{
+				ast.TupleSpec{ast.Primitive("int"), ast.Primitive("string")},
+				ast.FuncSpec{Arg: ast.TypeVar("t2"), Ret: ast.TypeVar("t2")},
+			},
+		},
+		{
+			// let f = x -> (f 1, f "a");
+			Name: "monomorphic-within-group",
+			Let: []ast.LetDecl{{
+				Ident: "f",
+				Binding: funcLit("x", ast.Expr{Node: ast.TupleLit{
+					call(ident("f"), intLit(1)),
+					call(ident("f"), ast.Expr{Node: ast.StringLit("a")}),
+				}}),
+			}},
+			WantedErr: true,
+		},
+		{
+			// let a = add b 1; let b = add a 1;
+			Name: "recursive-values",
+			Let: []ast.LetDecl{
+				{Ident: "a", Binding: addOne(ident("b"))},
+				{Ident: "b", Binding: addOne(ident("a"))},
+			},
+			WantedErr: true,
+		},
+		{
+			// let a = add a 1;
+			Name:      "self-recursive-value",
+			Let:       []ast.LetDecl{{Ident: "a", Binding: addOne(ident("a"))}},
+			WantedErr: true,
+		},
+		{
+			// let f = x -> add g x; let g = f 1;
+			Name: "recursive-function-and-value",
+			Let: []ast.LetDecl{
+				{
+					Ident: "f",
+					Binding: funcLit(
+						"x",
+						call(ident("add"), ident("g"), ident("x")),
+					),
+				},
+				{Ident: "g", Binding: call(ident("f"), intLit(1))},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "duplicate-definition",
+			Let: []ast.LetDecl{
+				{Ident: "x", Binding: intLit(1)},
+				{Ident: "x", Binding: intLit(2)},
+			},
+			WantedErr: true,
+		},
+		{
+			// let main = x -> add x 1;
+			Name: "function-valued-main",
+			Let: []ast.LetDecl{{
+				Ident:   "main",
+				Binding: funcLit("x", addOne(ident("x"))),
+			}},
+			WantedErr: true,
+		},
+		{
+			Name:      "unknown-identifier",
+			Let:       []ast.LetDecl{{Ident: "x", Binding: ident("y")}},
+			WantedErr: true,
+		},
+		{
+			// let id : int -> int = x -> x;
+			Name: "annotated-let",
+			Let: []ast.LetDecl{{
+				Ident:   "id",
+				Type:    ast.FuncSpec{Arg: intRef, Ret: intRef},
+				Binding: funcLit("x", ident("x")),
+			}},
+			Wanted: []ast.Type{fromInt(ast.Primitive("int"))},
+		},
+		{
+			// let f : int -> int = x -> f x;
+			Name: "annotated-recursion",
+			Let: []ast.LetDecl{{
+				Ident:   "f",
+				Type:    ast.FuncSpec{Arg: intRef, Ret: intRef},
+				Binding: funcLit("x", call(ident("f"), ident("x"))),
+			}},
+			Wanted: []ast.Type{fromInt(ast.Primitive("int"))},
+		},
+		{
+			// let f : string -> int = x -> add x 1;
+			Name: "annotated-let-mismatch",
+			Let: []ast.LetDecl{{
+				Ident: "f",
+				Type: ast.FuncSpec{
+					Arg: ast.TypeRef{Name: "string"},
+					Ret: intRef,
+				},
+				Binding: funcLit("x", addOne(ident("x"))),
+			}},
+			WantedErr: true,
+		},
+		{
+			// let id = (x : int) -> x;
+			Name: "annotated-arg",
+			Let: []ast.LetDecl{{
+				Ident: "id",
+				Binding: ast.Expr{Node: ast.FuncLit{
+					Arg:     "x",
+					ArgType: intRef,
+					Body:    ident("x"),
+				}},
+			}},
+			Wanted: []ast.Type{fromInt(ast.Primitive("int"))},
+		},
+		{
+			// let f = (x : string) -> add x 1;
+			Name: "annotated-arg-mismatch",
+			Let: []ast.LetDecl{{
+				Ident: "f",
+				Binding: ast.Expr{Node: ast.FuncLit{
+					Arg:     "x",
+					ArgType: ast.TypeRef{Name: "string"},
+					Body:    addOne(ident("x")),
+				}},
+			}},
+			WantedErr: true,
+		},
+		{
+			// let f : 'a -> 'a = x -> add x 1;
+			Name: "annotated-let-less-polymorphic",
+			Let: []ast.LetDecl{{
+				Ident:   "f",
+				Type:    ast.FuncSpec{Arg: varA, Ret: varA},
+				Binding: funcLit("x", addOne(ident("x"))),
+			}},
+			WantedErr: true,
+		},
+		{
+			// let f : 'a -> 'b = x -> x;
+			Name: "annotated-let-same-vars",
+			Let: []ast.LetDecl{{
+				Ident:   "f",
+				Type:    ast.FuncSpec{Arg: varA, Ret: ast.TypeVar("b")},
+				Binding: funcLit("x", ident("x")),
+			}},
+			WantedErr: true,
+		},
+		{
+			// let f = (x : 'a) -> add x 1;
+			Name: "annotated-arg-less-polymorphic",
+			Let: []ast.LetDecl{{
+				Ident: "f",
+				Binding: ast.Expr{Node: ast.FuncLit{
+					Arg:     "x",
+					ArgType: varA,
+					Body:    addOne(ident("x")),
+				}},
+			}},
+			WantedErr: true,
+		},
+		{
+			// let id : 't0 -> 't0 = x -> x;
+			Name: "annotated-let-supply-names",
+			Let: []ast.LetDecl{{
+				Ident:   "id",
+				Type:    ast.FuncSpec{Arg: varT0, Ret: varT0},
+				Binding: funcLit("x", ident("x")),
+			}},
+			Wanted: []ast.Type{
+				ast.FuncSpec{Arg: ast.TypeVar("t2"), Ret: ast.TypeVar("t2")},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{Package: "main"}
+			for _, letDecl := range testCase.Let {
+				input.Stmts = append(input.Stmts, letDecl)
+			}
+
+			got, err := File(env, input)
+			if err != nil {
+				if !testCase.WantedErr {
+					t.Fatal("Unexpected error:", err)
+				}
+				return
+			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
+
+			if len(got.Stmts) != len(testCase.Wanted) {
+				t.Fatalf(
+					"Wanted %d stmts; got %d",
+					len(testCase.Wanted),
+					len(got.Stmts),
+				)
+			}
+			for i, stmt := range got.Stmts {
+				letDecl := stmt.(ast.LetDecl)
+				if letDecl.Ident != testCase.Let[i].Ident {
+					t.Fatalf(
+						"Wanted let decl '%s' at %d; got '%s'",
+						testCase.Let[i].Ident,
+						i,
+						letDecl.Ident,
+					)
+				}
+				if !letDecl.Binding.Type.EqualType(testCase.Wanted[i]) {
+					t.Fatalf(
+						"'%s': WANTED:\n%# v\n\nGOT:\n%# v\n",
+						letDecl.Ident,
+						pretty.Formatter(testCase.Wanted[i]),
+						pretty.Formatter(letDecl.Binding.Type),
+					)
+				}
+			}
+		})
+	}
+}
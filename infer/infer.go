@@ -2,97 +2,369 @@ package infer
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/kr/pretty"
 	"github.com/weberc2/gallium/ast"
 )
 
-type Environment map[ast.Ident]ast.Type
+// Scheme is a type which is universally quantified over `Vars`. Each time an
+// identifier bound to a scheme is referenced, the quantified variables are
+// replaced with fresh type variables so the identifier may be used at
+// different types.
+type Scheme struct {
+	Vars []ast.TypeVar
+	Type ast.Type
+}
+
+// Mono returns a scheme which quantifies none of the type variables in `t`.
+func Mono(t ast.Type) Scheme { return Scheme{Type: t} }
+
+// Instantiate returns the scheme's type with each quantified variable
+// replaced by a fresh type variable from `supply`.
+func (s Scheme) Instantiate(supply *Supply) ast.Type {
+	if len(s.Vars) < 1 {
+		return s.Type
+	}
+	fresh := make(map[ast.TypeVar]ast.Type, len(s.Vars))
+	for _, v := range s.Vars {
+		fresh[v] = supply.Fresh()
+	}
+	return s.Type.Replace(fresh)
+}
+
+func (s Scheme) freeTypeVars() []ast.TypeVar {
+	var out []ast.TypeVar
+	for _, tv := range FreeTypeVars(s.Type) {
+		if !ContainsTypeVar(s.Vars, tv) {
+			out = append(out, tv)
+		}
+	}
+	return out
+}
+
+func (s Scheme) String() string {
+	if len(s.Vars) < 1 {
+		return s.Type.String()
+	}
+	vars := make([]string, len(s.Vars))
+	for i, v := range s.Vars {
+		vars[i] = v.String()
+	}
+	return "forall " + strings.Join(vars, " ") + ". " + s.Type.String()
+}
+
+type Environment map[ast.Ident]Scheme
 
 func (e Environment) Copy() Environment {
 	e2 := make(Environment, len(e))
-	for i, t := range e {
-		e2[i] = t
+	for i, s := range e {
+		e2[i] = s
 	}
 	return e2
 }
 
-func (e Environment) Add(ident ast.Ident, t ast.Type) Environment {
+func (e Environment) Add(ident ast.Ident, s Scheme) Environment {
 	e2 := e.Copy()
-	e2[ident] = t
+	e2[ident] = s
 	return e2
 }
 
-var r = 'a' - 1
+func (e Environment) freeTypeVars() []ast.TypeVar {
+	var out []ast.TypeVar
+	for _, s := range e {
+		for _, tv := range s.freeTypeVars() {
+			if !ContainsTypeVar(out, tv) {
+				out = append(out, tv)
+			}
+		}
+	}
+	return out
+}
 
-func genNewType() ast.Type {
-	r += 1
-	return ast.TypeVar(r)
+// Generalize returns a scheme for `t` which quantifies every type variable in
+// `t` that is not free in `env`.
+func Generalize(env Environment, t ast.Type) Scheme {
+	envVars := env.freeTypeVars()
+	var vars []ast.TypeVar
+	for _, tv := range FreeTypeVars(t) {
+		if !ContainsTypeVar(envVars, tv) {
+			vars = append(vars, tv)
+		}
+	}
+	return Scheme{Vars: vars, Type: t}
 }
 
-func AnnotateExpr(expr ast.Expr, env Environment) (ast.Expr, error) {
+// FreeTypeVars returns the type variables in `t` in order of their first
+// occurrence.
+func FreeTypeVars(t ast.Type) []ast.TypeVar {
+	var out []ast.TypeVar
+	var visit func(t ast.Type)
+	visit = func(t ast.Type) {
+		switch typ := t.(type) {
+		case ast.Primitive:
+		case ast.TypeVar:
+			if !ContainsTypeVar(out, typ) {
+				out = append(out, typ)
+			}
+		case ast.FuncSpec:
+			visit(typ.Arg)
+			visit(typ.Ret)
+		case ast.TupleSpec:
+			for _, t := range typ {
+				visit(t)
+			}
+		case ast.TypeRef:
+			for _, arg := range typ.Args {
+				visit(arg)
+			}
+		case ast.RecordSpec:
+			for _, f := range typ.Fields {
+				visit(f.Type)
+			}
+			if typ.IsOpen() {
+				visit(typ.Rest)
+			}
+		default:
+			panic(fmt.Sprintf(
+				"FreeTypeVars() not implemented for %# v",
+				pretty.Formatter(t),
+			))
+		}
+	}
+	visit(t)
+	return out
+}
+
+// ContainsTypeVar returns true if `tv` is one of `tvs`.
+func ContainsTypeVar(tvs []ast.TypeVar, tv ast.TypeVar) bool {
+	for _, v := range tvs {
+		if v == tv {
+			return true
+		}
+	}
+	return false
+}
+
+// Supply generates fresh type variables named `t0`, `t1`, etc. A supply
+// belongs to a single inference; it isn't safe for concurrent use, but
+// inferences with separate supplies may run concurrently.
+type Supply struct {
+	next  int
+	avoid []ast.TypeVar
+}
+
+// NewSupply returns a supply whose type variables won't collide with those
+// which are free in `env`.
+func NewSupply(env Environment) *Supply {
+	return &Supply{avoid: env.freeTypeVars()}
+}
+
+// Fresh returns a type variable which the supply hasn't returned before.
+func (s *Supply) Fresh() ast.TypeVar {
+	for {
+		tv := ast.TypeVar("t" + strconv.Itoa(s.next))
+		s.next++
+		if !ContainsTypeVar(s.avoid, tv) {
+			return tv
+		}
+	}
+}
+
+func AnnotateExpr(
+	expr ast.Expr,
+	env Environment,
+	supply *Supply,
+) (ast.Expr, error) {
 	switch node := expr.Node.(type) {
 	case ast.IntLit:
-		return ast.Expr{Type: ast.Primitive("int"), Node: node}, nil
+		return ast.Expr{
+			Type: ast.Primitive("int"),
+			Node: node,
+			Span: expr.Span,
+		}, nil
 	case ast.StringLit:
-		return ast.Expr{Type: ast.Primitive("string"), Node: node}, nil
+		return ast.Expr{
+			Type: ast.Primitive("string"),
+			Node: node,
+			Span: expr.Span,
+		}, nil
 	case ast.Ident:
-		if t, found := env[node]; found {
-			return ast.Expr{Type: t, Node: node}, nil
+		if s, found := env[node]; found {
+			return ast.Expr{
+				Type: s.Instantiate(supply),
+				Node: node,
+				Span: expr.Span,
+			}, nil
+		}
+		return ast.Expr{}, TypeError{
+			Span: expr.Span,
+			Err:  fmt.Errorf("Unknown identifier: '%s'", node),
 		}
-		return ast.Expr{}, fmt.Errorf("Unknown identifier: '%s'", node)
 	case ast.TupleLit:
 		var err error
 		out := make(ast.TupleLit, len(node))
 		ts := make(ast.TupleSpec, len(node))
 		for i, expr := range node {
-			out[i], err = AnnotateExpr(expr, env)
+			out[i], err = AnnotateExpr(expr, env, supply)
 			if err != nil {
 				return ast.Expr{}, err
 			}
 			ts[i] = out[i].Type
 		}
-		return ast.Expr{Type: ts, Node: out}, nil
+		return ast.Expr{Type: ts, Node: out, Span: expr.Span}, nil
 	case ast.Block:
-		for _, stmt := range node.Stmts {
-			if letDecl, ok := stmt.(ast.LetDecl); ok {
-				binding, err := Infer(env, letDecl.Binding)
+		stmts := make([]ast.Stmt, len(node.Stmts))
+		for i, stmt := range node.Stmts {
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				binding, scheme, err := annotateLet(x, env, supply)
 				if err != nil {
 					return ast.Expr{}, err
 				}
-				env = env.Add(letDecl.Ident, binding.Type)
+				x.Binding = binding
+				stmts[i] = x
+				env = env.Add(x.Ident, scheme)
+			case ast.Expr:
+				annotated, err := AnnotateExpr(x, env, supply)
+				if err != nil {
+					return ast.Expr{}, err
+				}
+				stmts[i] = annotated
+			default:
+				stmts[i] = stmt
 			}
 		}
-		inner, err := AnnotateExpr(node.Expr, env)
+		// a block without an expression (e.g., `{ PrintInt 1; }`) evaluates
+		// to the unit value
+		if node.Expr.Node == nil {
+			node.Expr = ast.Expr{Node: ast.TupleLit{}, Span: expr.Span}
+		}
+		inner, err := AnnotateExpr(node.Expr, env, supply)
 		if err != nil {
 			return ast.Expr{}, err
 		}
 		return ast.Expr{
-			Type: genNewType(),
-			Node: ast.Block{Stmts: node.Stmts, Expr: inner},
+			Type: inner.Type,
+			Node: ast.Block{Stmts: stmts, Expr: inner},
+			Span: expr.Span,
 		}, nil
 	case ast.FuncLit:
-		newEnv := env.Add(node.Arg, genNewType())
-		body, err := AnnotateExpr(node.Body, newEnv)
+		argType := supply.Fresh()
+		body, err := AnnotateExpr(
+			node.Body,
+			env.Add(node.Arg, Mono(argType)),
+			supply,
+		)
 		if err != nil {
 			return ast.Expr{}, err
 		}
 		return ast.Expr{
-			Type: ast.FuncSpec{Arg: newEnv[node.Arg], Ret: genNewType()},
-			Node: ast.FuncLit{Arg: node.Arg, Body: body},
+			Type: ast.FuncSpec{Arg: argType, Ret: supply.Fresh()},
+			Node: ast.FuncLit{
+				Arg:     node.Arg,
+				ArgType: node.ArgType,
+				Body:    body,
+			},
+			Span: expr.Span,
 		}, nil
 	case ast.Call:
-		fn, err := AnnotateExpr(node.Fn, env)
+		fn, err := AnnotateExpr(node.Fn, env, supply)
 		if err != nil {
 			return ast.Expr{}, err
 		}
-		arg, err := AnnotateExpr(node.Arg, env)
+		arg, err := AnnotateExpr(node.Arg, env, supply)
 		if err != nil {
 			return ast.Expr{}, err
 		}
 		return ast.Expr{
-			Type: genNewType(),
+			Type: supply.Fresh(),
 			Node: ast.Call{Fn: fn, Arg: arg},
+			Span: expr.Span,
+		}, nil
+	case ast.If:
+		cond, err := AnnotateExpr(node.Cond, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		then, err := AnnotateExpr(node.Then, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		els, err := AnnotateExpr(node.Else, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		return ast.Expr{
+			Type: supply.Fresh(),
+			Node: ast.If{Cond: cond, Then: then, Else: els},
+			Span: expr.Span,
+		}, nil
+	case ast.Match:
+		scrutinee, err := AnnotateExpr(node.Expr, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		cases := make([]ast.Case, len(node.Cases))
+		for i, c := range node.Cases {
+			bound := Environment{}
+			pattern, err := AnnotatePattern(c.Pattern, env, bound, supply)
+			if err != nil {
+				return ast.Expr{}, err
+			}
+			inner := env.Copy()
+			for ident, s := range bound {
+				inner[ident] = s
+			}
+			body, err := AnnotateExpr(c.Body, inner, supply)
+			if err != nil {
+				return ast.Expr{}, err
+			}
+			cases[i] = ast.Case{Pattern: pattern, Body: body, Span: c.Span}
+		}
+		return ast.Expr{
+			Type: supply.Fresh(),
+			Node: ast.Match{Expr: scrutinee, Cases: cases},
+			Span: expr.Span,
+		}, nil
+	case ast.RecordLit:
+		fields, err := annotateFields(node, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		types := make([]ast.Field, len(fields))
+		for i, f := range fields {
+			types[i] = ast.Field{Name: f.Name, Type: f.Value.Type}
+		}
+		return ast.Expr{
+			Type: ast.NewRecordSpec(types),
+			Node: ast.RecordLit(fields),
+			Span: expr.Span,
+		}, nil
+	case ast.Project:
+		record, err := AnnotateExpr(node.Record, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		return ast.Expr{
+			Type: supply.Fresh(),
+			Node: ast.Project{Record: record, Field: node.Field},
+			Span: expr.Span,
+		}, nil
+	case ast.RecordUpdate:
+		record, err := AnnotateExpr(node.Record, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		fields, err := annotateFields(node.Fields, env, supply)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		return ast.Expr{
+			Type: supply.Fresh(),
+			Node: ast.RecordUpdate{Record: record, Fields: fields},
+			Span: expr.Span,
 		}, nil
 	default:
 		panic(fmt.Sprintf(
@@ -102,8 +374,25 @@ func AnnotateExpr(expr ast.Expr, env Environment) (ast.Expr, error) {
 	}
 }
 
+// Constraint requires that `L` and `R` be the same type. `Span` is the
+// region of source which imposes the constraint; it's used to report where
+// unification failed.
 type Constraint struct {
 	L, R ast.Type
+	Span ast.Span
+}
+
+// TypeError is an error along with the span of source which caused it.
+type TypeError struct {
+	Span ast.Span
+	Err  error
+}
+
+func (err TypeError) Error() string {
+	if !err.Span.IsValid() {
+		return err.Err.Error()
+	}
+	return err.Span.String() + ": " + err.Err.Error()
 }
 
 func CollectExpr(expr ast.Expr) ([]Constraint, error) {
@@ -123,17 +412,46 @@ func CollectExpr(expr ast.Expr) ([]Constraint, error) {
 		}
 		return constraints, nil
 	case ast.Block:
-		return CollectExpr(node.Expr)
+		// the statements' constraints are collected along with the
+		// block's expression's so they're solved together
+		var constraints []Constraint
+		for _, stmt := range node.Stmts {
+			var cs []Constraint
+			var err error
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				cs, err = letConstraints(x)
+			case ast.Expr:
+				cs, err = CollectExpr(x)
+			}
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, cs...)
+		}
+		cs, err := CollectExpr(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return append(constraints, cs...), nil
 	case ast.FuncLit:
 		if spec, isFunc := expr.Type.(ast.FuncSpec); isFunc {
 			bodyConstraints, err := CollectExpr(node.Body)
 			if err != nil {
 				return nil, err
 			}
-			return append(
+			constraints := append(
 				bodyConstraints,
-				Constraint{node.Body.Type, spec.Ret},
-			), nil
+				Constraint{node.Body.Type, spec.Ret, node.Body.Span},
+			)
+			if node.ArgType != nil {
+				// the annotation is appended so it's solved first (see Unify)
+				constraints = append(
+					constraints,
+					Constraint{spec.Arg, node.ArgType, expr.Span},
+				)
+			}
+			return constraints, nil
 		}
 		return nil, fmt.Errorf(
 			"Not a function: %# v",
@@ -152,8 +470,8 @@ func CollectExpr(expr ast.Expr) ([]Constraint, error) {
 			}
 			return append(
 				append(fnConstraints, argConstraints...),
-				Constraint{t, t.Ret},
-				Constraint{t.Arg, t.Arg},
+				Constraint{t, t.Ret, expr.Span},
+				Constraint{t.Arg, t.Arg, expr.Span},
 			), nil
 		case ast.TypeVar:
 			fnConstraints, err := CollectExpr(node.Fn)
@@ -169,11 +487,96 @@ func CollectExpr(expr ast.Expr) ([]Constraint, error) {
 				Constraint{
 					node.Fn.Type,
 					ast.FuncSpec{node.Arg.Type, expr.Type},
+					expr.Span,
 				},
 			), nil
 		default:
 			panic(pretty.Sprint("Unexpected expr type:", expr.Type))
 		}
+	case ast.If:
+		var constraints []Constraint
+		for _, expr := range []ast.Expr{node.Cond, node.Then, node.Else} {
+			cs, err := CollectExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, cs...)
+		}
+		return append(
+			constraints,
+			Constraint{node.Cond.Type, ast.Primitive("bool"), node.Cond.Span},
+			Constraint{node.Then.Type, expr.Type, node.Then.Span},
+			Constraint{node.Else.Type, expr.Type, node.Else.Span},
+		), nil
+	case ast.Match:
+		constraints, err := CollectExpr(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range node.Cases {
+			constraints = append(constraints, CollectPattern(c.Pattern)...)
+			cs, err := CollectExpr(c.Body)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(
+				append(constraints, cs...),
+				Constraint{node.Expr.Type, c.Pattern.Type, c.Pattern.Span},
+				Constraint{c.Body.Type, expr.Type, c.Body.Span},
+			)
+		}
+		return constraints, nil
+	case ast.RecordLit:
+		var constraints []Constraint
+		for _, f := range node {
+			cs, err := CollectExpr(f.Value)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, cs...)
+		}
+		return constraints, nil
+	case ast.Project:
+		// the record may be any record with the field, i.e., it's of the
+		// open record type `{field: t | r}` where `t` is the projection's
+		// type
+		constraints, err := CollectExpr(node.Record)
+		if err != nil {
+			return nil, err
+		}
+		rs := ast.RecordSpec{
+			Fields: []ast.Field{{Name: node.Field, Type: expr.Type}},
+			Rest:   rowVar(expr.Type.(ast.TypeVar)),
+		}
+		return append(
+			constraints,
+			Constraint{node.Record.Type, rs, expr.Span},
+		), nil
+	case ast.RecordUpdate:
+		// the record may be any record with the updated fields (whose types
+		// must be those of the new values), and the update is of the same
+		// type as the record
+		constraints, err := CollectExpr(node.Record)
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]ast.Field, len(node.Fields))
+		for i, f := range node.Fields {
+			cs, err := CollectExpr(f.Value)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, cs...)
+			fields[i] = ast.Field{Name: f.Name, Type: f.Value.Type}
+		}
+		rs := ast.NewRecordSpec(fields).WithRest(
+			rowVar(expr.Type.(ast.TypeVar)),
+		)
+		return append(
+			constraints,
+			Constraint{node.Record.Type, expr.Type, node.Record.Span},
+			Constraint{expr.Type, rs, expr.Span},
+		), nil
 	default:
 		panic(fmt.Sprintf("Invalid expr node: %# v", pretty.Formatter(node)))
 	}
@@ -188,6 +591,13 @@ func (s Substitution) Equal(other Substitution) bool {
 	return s.Var == other.Var && s.Type.EqualType(other.Type)
 }
 
+// Unify solves `constraints`, returning the substitutions which make the two
+// types of each constraint equal. The constraints are unified last to first,
+// so when constraints contradict each other, the error is reported against
+// the span of the earliest of them. A constraint which should take precedence
+// (e.g., that a binding has its annotated type) is therefore appended after
+// the constraints it may contradict, so they're checked against it and a
+// mismatch is reported where the binding contradicts it.
 func Unify(constraints []Constraint) ([]Substitution, error) {
 	if len(constraints) < 1 {
 		return nil, nil
@@ -196,8 +606,12 @@ func Unify(constraints []Constraint) ([]Substitution, error) {
 	if err != nil {
 		return nil, err
 	}
-	t1, err := UnifyOne(Apply(t2, constraints[0].L), Apply(t2, constraints[0].R))
+	c := constraints[0]
+	t1, err := UnifyOne(Apply(t2, c.L), Apply(t2, c.R))
 	if err != nil {
+		if _, ok := err.(TypeError); !ok && c.Span.IsValid() {
+			return nil, TypeError{Span: c.Span, Err: err}
+		}
 		return nil, err
 	}
 	return append(t1, t2...), nil
@@ -217,16 +631,16 @@ func UnifyOne(t1, t2 ast.Type) ([]Substitution, error) {
 		if tv2, ok := t2.(ast.TypeVar); ok && tv == tv2 {
 			return nil, nil
 		}
-		return []Substitution{{tv, t2}}, nil
+		return bind(tv, t2)
 	}
 	if tv, ok := t2.(ast.TypeVar); ok {
-		return []Substitution{{tv, t1}}, nil
+		return bind(tv, t1)
 	}
 	if spec1, ok := t1.(ast.FuncSpec); ok {
 		if spec2, ok := t2.(ast.FuncSpec); ok {
 			return Unify([]Constraint{
-				{spec1.Arg, spec2.Arg},
-				{spec1.Ret, spec2.Ret},
+				{L: spec1.Arg, R: spec2.Arg},
+				{L: spec1.Ret, R: spec2.Ret},
 			})
 		}
 	}
@@ -235,15 +649,61 @@ func UnifyOne(t1, t2 ast.Type) ([]Substitution, error) {
 			if len(ts1) == len(ts2) {
 				constraints := make([]Constraint, len(ts1))
 				for i, t := range ts1 {
-					constraints[i] = Constraint{t, ts2[i]}
+					constraints[i] = Constraint{L: t, R: ts2[i]}
 				}
 				return Unify(constraints)
 			}
 		}
 	}
+	if ref1, ok := t1.(ast.TypeRef); ok {
+		if ref2, ok := t2.(ast.TypeRef); ok {
+			if ref1.Name == ref2.Name && len(ref1.Args) == len(ref2.Args) {
+				constraints := make([]Constraint, len(ref1.Args))
+				for i, t := range ref1.Args {
+					constraints[i] = Constraint{L: t, R: ref2.Args[i]}
+				}
+				return Unify(constraints)
+			}
+		}
+	}
+	if rs1, ok := t1.(ast.RecordSpec); ok {
+		if rs2, ok := t2.(ast.RecordSpec); ok {
+			return unifyRecords(rs1, rs2)
+		}
+		if rs1.IsOpen() {
+			return nil, fmt.Errorf("Type %v isn't a record", t2)
+		}
+	}
+	if rs2, ok := t2.(ast.RecordSpec); ok && rs2.IsOpen() {
+		return nil, fmt.Errorf("Type %v isn't a record", t1)
+	}
 	return nil, fmt.Errorf("Mismatched types: %v != %v", t1, t2)
 }
 
+// InfiniteTypeError is returned when unification would bind a type variable to
+// a type which contains that same variable, e.g., `'a = 'a -> 'b`.
+type InfiniteTypeError struct {
+	Var  ast.TypeVar
+	Type ast.Type
+}
+
+func (err InfiniteTypeError) Error() string {
+	return fmt.Sprintf(
+		"Infinite type: %v occurs in %v",
+		err.Var,
+		err.Type,
+	)
+}
+
+// bind returns a substitution of `t` for `tv` unless `tv` occurs in `t`, in
+// which case it returns an InfiniteTypeError.
+func bind(tv ast.TypeVar, t ast.Type) ([]Substitution, error) {
+	if ContainsTypeVar(FreeTypeVars(t), tv) {
+		return nil, InfiniteTypeError{Var: tv, Type: t}
+	}
+	return []Substitution{{tv, t}}, nil
+}
+
 func Substitute(replace ast.Type, tv ast.TypeVar, t ast.Type) ast.Type {
 	switch typ := t.(type) {
 	case ast.Primitive:
@@ -259,15 +719,33 @@ func Substitute(replace ast.Type, tv ast.TypeVar, t ast.Type) ast.Type {
 			Ret: Substitute(replace, tv, typ.Ret),
 		}
 	case ast.TypeRef:
-		// TODO: It's not currently clear where this fits into the reference
-		// implementation; maybe this is made redundant by TypeVar?
-		panic("TypeRef not supported")
+		if len(typ.Args) < 1 {
+			return t
+		}
+		args := make([]ast.Type, len(typ.Args))
+		for i, arg := range typ.Args {
+			args[i] = Substitute(replace, tv, arg)
+		}
+		return ast.TypeRef{Name: typ.Name, Decl: typ.Decl, Args: args}
 	case ast.TupleSpec:
 		out := make(ast.TupleSpec, len(typ))
 		for i, t := range typ {
 			out[i] = Substitute(replace, tv, t)
 		}
 		return out
+	case ast.RecordSpec:
+		fields := make([]ast.Field, len(typ.Fields))
+		for i, f := range typ.Fields {
+			fields[i] = ast.Field{
+				Name: f.Name,
+				Type: Substitute(replace, tv, f.Type),
+			}
+		}
+		out := ast.RecordSpec{Fields: fields, Rest: typ.Rest}
+		if typ.IsOpen() && typ.Rest == tv {
+			return out.WithRest(replace)
+		}
+		return out
 	default:
 		panic(fmt.Sprintf(
 			"Substitute() not implemented for %# v",
@@ -288,30 +766,57 @@ func Apply(subs []Substitution, t ast.Type) ast.Type {
 func ApplyExpr(subs []Substitution, expr ast.Expr) ast.Expr {
 	switch node := expr.Node.(type) {
 	case ast.IntLit:
-		return ast.Expr{Node: node, Type: Apply(subs, expr.Type)}
+		return ast.Expr{
+			Node: node,
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
 	case ast.StringLit:
-		return ast.Expr{Node: node, Type: Apply(subs, expr.Type)}
+		return ast.Expr{
+			Node: node,
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
 	case ast.Ident:
-		return ast.Expr{Node: node, Type: Apply(subs, expr.Type)}
+		return ast.Expr{
+			Node: node,
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
 	case ast.TupleLit:
 		tl := make(ast.TupleLit, len(node))
 		for i, expr := range node {
 			tl[i] = ApplyExpr(subs, expr)
 		}
-		return ast.Expr{Node: tl, Type: Apply(subs, expr.Type)}
+		return ast.Expr{Node: tl, Type: Apply(subs, expr.Type), Span: expr.Span}
 	case ast.Block:
+		stmts := make([]ast.Stmt, len(node.Stmts))
+		for i, stmt := range node.Stmts {
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				x.Binding = ApplyExpr(subs, x.Binding)
+				stmts[i] = x
+			case ast.Expr:
+				stmts[i] = ApplyExpr(subs, x)
+			default:
+				stmts[i] = stmt
+			}
+		}
 		inner := ApplyExpr(subs, node.Expr)
 		return ast.Expr{
 			Type: inner.Type,
-			Node: ast.Block{Stmts: node.Stmts, Expr: inner},
+			Node: ast.Block{Stmts: stmts, Expr: inner},
+			Span: expr.Span,
 		}
 	case ast.FuncLit:
 		return ast.Expr{
 			Node: ast.FuncLit{
-				Arg:  node.Arg,
-				Body: ApplyExpr(subs, node.Body),
+				Arg:     node.Arg,
+				ArgType: node.ArgType,
+				Body:    ApplyExpr(subs, node.Body),
 			},
 			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
 		}
 	case ast.Call:
 		return ast.Expr{
@@ -320,6 +825,55 @@ func ApplyExpr(subs []Substitution, expr ast.Expr) ast.Expr {
 				Arg: ApplyExpr(subs, node.Arg),
 			},
 			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
+	case ast.If:
+		return ast.Expr{
+			Node: ast.If{
+				Cond: ApplyExpr(subs, node.Cond),
+				Then: ApplyExpr(subs, node.Then),
+				Else: ApplyExpr(subs, node.Else),
+			},
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
+	case ast.Match:
+		cases := make([]ast.Case, len(node.Cases))
+		for i, c := range node.Cases {
+			cases[i] = ast.Case{
+				Pattern: ApplyPattern(subs, c.Pattern),
+				Body:    ApplyExpr(subs, c.Body),
+				Span:    c.Span,
+			}
+		}
+		return ast.Expr{
+			Node: ast.Match{Expr: ApplyExpr(subs, node.Expr), Cases: cases},
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
+	case ast.RecordLit:
+		return ast.Expr{
+			Node: ast.RecordLit(applyFields(subs, node)),
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
+	case ast.Project:
+		return ast.Expr{
+			Node: ast.Project{
+				Record: ApplyExpr(subs, node.Record),
+				Field:  node.Field,
+			},
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
+		}
+	case ast.RecordUpdate:
+		return ast.Expr{
+			Node: ast.RecordUpdate{
+				Record: ApplyExpr(subs, node.Record),
+				Fields: applyFields(subs, node.Fields),
+			},
+			Type: Apply(subs, expr.Type),
+			Span: expr.Span,
 		}
 	default:
 		panic(fmt.Sprintf(
@@ -329,9 +883,79 @@ func ApplyExpr(subs []Substitution, expr ast.Expr) ast.Expr {
 	}
 }
 
+// annotateLet annotates the binding of the let statement `letDecl` (of a
+// block) and returns it with the scheme of the let's identifier. The
+// binding's constraints are solved so its type can be generalized, but
+// they're also collected with the enclosing block's (see CollectExpr), since
+// they may constrain the types of variables bound outside of the block
+// (e.g., in `x -> { let y = x * 2; y }`, `x` is an int).
+func annotateLet(
+	letDecl ast.LetDecl,
+	env Environment,
+	supply *Supply,
+) (ast.Expr, Scheme, error) {
+	binding, err := AnnotateExpr(letDecl.Binding, env, supply)
+	if err != nil {
+		return ast.Expr{}, Scheme{}, err
+	}
+	letDecl.Binding = binding
+	constraints, err := letConstraints(letDecl)
+	if err != nil {
+		return ast.Expr{}, Scheme{}, err
+	}
+	subs, err := Unify(constraints)
+	if err != nil {
+		return ast.Expr{}, Scheme{}, err
+	}
+
+	// the type variables which the solution binds in the environment are
+	// still bound by the environment, so they mustn't be generalized
+	solved := make(Environment, len(env))
+	for ident, s := range env {
+		solved[ident] = Scheme{Vars: s.Vars, Type: Apply(subs, s.Type)}
+	}
+	return binding, Generalize(solved, Apply(subs, binding.Type)), nil
+}
+
+// letConstraints returns the constraints of the annotated binding of the let
+// statement `letDecl` and, if the let is annotated, the constraint that the
+// binding has the annotated type.
+func letConstraints(letDecl ast.LetDecl) ([]Constraint, error) {
+	constraints, err := CollectExpr(letDecl.Binding)
+	if err != nil {
+		return nil, err
+	}
+	if letDecl.Type != nil {
+		// the annotation is appended so it's solved first (see Unify)
+		constraints = append(
+			constraints,
+			Constraint{letDecl.Binding.Type, letDecl.Type, letDecl.Span},
+		)
+	}
+	return constraints, nil
+}
+
 func Infer(env Environment, expr ast.Expr) (ast.Expr, error) {
-	annotated, err := AnnotateExpr(expr, env)
-	r = 'a' - 1
+	supply := NewSupply(env)
+	r := newResolver(nil, supply).binding()
+	resolved, err := r.resolveAnnotations(expr)
+	if err != nil {
+		return ast.Expr{}, err
+	}
+	return infer(env, resolved, r.rigid, supply)
+}
+
+// infer is like Infer, except that it draws type variables from the provided
+// supply so it may be called while annotating an enclosing expression. The
+// annotations of `expr` must have been resolved, and `rigid` holds their type
+// variables (see checkRigid).
+func infer(
+	env Environment,
+	expr ast.Expr,
+	rigid map[ast.TypeVar]rigidVar,
+	supply *Supply,
+) (ast.Expr, error) {
+	annotated, err := AnnotateExpr(expr, env, supply)
 	if err != nil {
 		return ast.Expr{}, err
 	}
@@ -343,5 +967,12 @@ func Infer(env Environment, expr ast.Expr) (ast.Expr, error) {
 	if err != nil {
 		return ast.Expr{}, err
 	}
-	return ApplyExpr(subs, annotated), nil
+	if err := checkRigid(subs, rigid); err != nil {
+		return ast.Expr{}, err
+	}
+	out := ApplyExpr(subs, annotated)
+	if err := CheckMatches(out); err != nil {
+		return ast.Expr{}, err
+	}
+	return out, nil
 }
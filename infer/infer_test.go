@@ -9,6 +9,10 @@ import (
 )
 
 func TestInfer(t *testing.T) {
+	intToInt := ast.FuncSpec{
+		Arg: ast.Primitive("int"),
+		Ret: ast.Primitive("int"),
+	}
 	testCases := []struct {
 		Name      string
 		Env       Environment
@@ -34,7 +38,7 @@ func TestInfer(t *testing.T) {
 		},
 		{
 			Name:  "string-ident-simple",
-			Env:   Environment{"foo": ast.Primitive("string")},
+			Env:   Environment{"foo": Mono(ast.Primitive("string"))},
 			Input: ast.Expr{Node: ast.Ident("foo")},
 			Wanted: ast.Expr{
 				Type: ast.Primitive("string"),
@@ -97,16 +101,16 @@ func TestInfer(t *testing.T) {
 					},
 				},
 				Type: ast.FuncSpec{
-					Arg: ast.TypeVar("a"),
+					Arg: ast.TypeVar("t0"),
 					Ret: ast.Primitive("int"),
 				},
 			},
 		},
 		{
 			// x => x
-			// TODO: The remaining typevar in `Wanted` should probably be 'a',
-			// but the functionally important thing is that the typevar is
-			// consistent.
+			// TODO: The remaining typevar in `Wanted` should probably be
+			// 't0, but the functionally important thing is that the typevar
+			// is consistent.
 			Name: "func-lit-identity",
 			Env:  Environment{},
 			Input: ast.Expr{Node: ast.FuncLit{
@@ -118,12 +122,51 @@ func TestInfer(t *testing.T) {
 					Arg: "x",
 					Body: ast.Expr{
 						Node: ast.Ident("x"),
-						Type: ast.TypeVar("b"),
+						Type: ast.TypeVar("t1"),
 					},
 				},
 				Type: ast.FuncSpec{
-					Arg: ast.TypeVar("b"),
-					Ret: ast.TypeVar("b"),
+					Arg: ast.TypeVar("t1"),
+					Ret: ast.TypeVar("t1"),
+				},
+			},
+		},
+		{
+			// (x, y -> y)
+			// `x` is free in the environment, so fresh type variables must
+			// not reuse its name.
+			Name: "fresh-typevars-avoid-env",
+			Env:  Environment{"x": Mono(ast.TypeVar("t0"))},
+			Input: ast.Expr{Node: ast.TupleLit{
+				ast.Expr{Node: ast.Ident("x")},
+				ast.Expr{Node: ast.FuncLit{
+					Arg:  "y",
+					Body: ast.Expr{Node: ast.Ident("y")},
+				}},
+			}},
+			Wanted: ast.Expr{
+				Type: ast.TupleSpec{
+					ast.TypeVar("t0"),
+					ast.FuncSpec{
+						Arg: ast.TypeVar("t2"),
+						Ret: ast.TypeVar("t2"),
+					},
+				},
+				Node: ast.TupleLit{
+					ast.Expr{Type: ast.TypeVar("t0"), Node: ast.Ident("x")},
+					ast.Expr{
+						Type: ast.FuncSpec{
+							Arg: ast.TypeVar("t2"),
+							Ret: ast.TypeVar("t2"),
+						},
+						Node: ast.FuncLit{
+							Arg: "y",
+							Body: ast.Expr{
+								Type: ast.TypeVar("t2"),
+								Node: ast.Ident("y"),
+							},
+						},
+					},
 				},
 			},
 		},
@@ -160,8 +203,11 @@ func TestInfer(t *testing.T) {
 				Node: ast.Block{
 					Stmts: []ast.Stmt{
 						ast.LetDecl{
-							Ident:   "x",
-							Binding: ast.Expr{Node: ast.StringLit("foo")},
+							Ident: "x",
+							Binding: ast.Expr{
+								Type: ast.Primitive("string"),
+								Node: ast.StringLit("foo"),
+							},
 						},
 					},
 					Expr: ast.Expr{
@@ -174,13 +220,13 @@ func TestInfer(t *testing.T) {
 		{
 			Name: "block-w-dependent-let-decls",
 			Env: Environment{
-				ast.Ident("add"): ast.FuncSpec{
+				ast.Ident("add"): Mono(ast.FuncSpec{
 					Arg: ast.Primitive("int"),
 					Ret: ast.FuncSpec{
 						Arg: ast.Primitive("int"),
 						Ret: ast.Primitive("int"),
 					},
-				},
+				}),
 			},
 			Input: ast.Expr{Node: ast.Block{
 				Stmts: []ast.Stmt{
@@ -203,22 +249,354 @@ func TestInfer(t *testing.T) {
 					Stmts: []ast.Stmt{
 						ast.LetDecl{
 							Ident: ast.Ident("y"),
+							Binding: ast.Expr{
+								Type: ast.Primitive("int"),
+								Node: ast.Call{
+									Fn: ast.Expr{
+										Type: ast.FuncSpec{
+											Arg: ast.Primitive("int"),
+											Ret: ast.Primitive("int"),
+										},
+										Node: ast.Call{
+											Fn: ast.Expr{
+												Type: ast.FuncSpec{
+													Arg: ast.Primitive("int"),
+													Ret: ast.FuncSpec{
+														Arg: ast.Primitive(
+															"int",
+														),
+														Ret: ast.Primitive(
+															"int",
+														),
+													},
+												},
+												Node: ast.Ident("add"),
+											},
+											Arg: ast.Expr{
+												Type: ast.Primitive("int"),
+												Node: ast.IntLit(1),
+											},
+										},
+									},
+									Arg: ast.Expr{
+										Type: ast.Primitive("int"),
+										Node: ast.IntLit(1),
+									},
+								},
+							},
+						},
+					},
+					Expr: ast.Expr{
+						Type: ast.Primitive("int"),
+						Node: ast.Ident("y"),
+					},
+				},
+			},
+		},
+		{
+			Name: "ident-polymorphic",
+			Env: Environment{
+				"id": Scheme{
+					Vars: []ast.TypeVar{"z"},
+					Type: ast.FuncSpec{
+						Arg: ast.TypeVar("z"),
+						Ret: ast.TypeVar("z"),
+					},
+				},
+			},
+			Input: ast.Expr{Node: ast.Call{
+				Fn:  ast.Expr{Node: ast.Ident("id")},
+				Arg: ast.Expr{Node: ast.IntLit(1)},
+			}},
+			Wanted: ast.Expr{
+				Type: ast.Primitive("int"),
+				Node: ast.Call{
+					Fn: ast.Expr{
+						Type: ast.FuncSpec{
+							Arg: ast.Primitive("int"),
+							Ret: ast.Primitive("int"),
+						},
+						Node: ast.Ident("id"),
+					},
+					Arg: ast.Expr{
+						Type: ast.Primitive("int"),
+						Node: ast.IntLit(1),
+					},
+				},
+			},
+		},
+		{
+			// { let x = 1; }
+			Name: "block-wo-expr",
+			Env:  Environment{},
+			Input: ast.Expr{Node: ast.Block{
+				Stmts: []ast.Stmt{
+					ast.LetDecl{
+						Ident:   "x",
+						Binding: ast.Expr{Node: ast.IntLit(1)},
+					},
+				},
+			}},
+			Wanted: ast.Expr{
+				Type: ast.TupleSpec{},
+				Node: ast.Block{
+					Stmts: []ast.Stmt{
+						ast.LetDecl{
+							Ident: "x",
+							Binding: ast.Expr{
+								Type: ast.Primitive("int"),
+								Node: ast.IntLit(1),
+							},
+						},
+					},
+					Expr: ast.Expr{Type: ast.TupleSpec{}, Node: ast.TupleLit{}},
+				},
+			},
+		},
+		{
+			// { let id = x -> x; (id 1, id "a") }
+			Name: "block-w-polymorphic-let-decl",
+			Env:  Environment{},
+			Input: ast.Expr{Node: ast.Block{
+				Stmts: []ast.Stmt{
+					ast.LetDecl{
+						Ident: "id",
+						Binding: ast.Expr{Node: ast.FuncLit{
+							Arg:  "x",
+							Body: ast.Expr{Node: ast.Ident("x")},
+						}},
+					},
+				},
+				Expr: ast.Expr{Node: ast.TupleLit{
+					ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("id")},
+						Arg: ast.Expr{Node: ast.IntLit(1)},
+					}},
+					ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("id")},
+						Arg: ast.Expr{Node: ast.StringLit("a")},
+					}},
+				}},
+			}},
+			Wanted: ast.Expr{
+				Type: ast.TupleSpec{
+					ast.Primitive("int"),
+					ast.Primitive("string"),
+				},
+				Node: ast.Block{
+					Stmts: []ast.Stmt{
+						ast.LetDecl{
+							Ident: "id",
+							Binding: ast.Expr{
+								Type: ast.FuncSpec{
+									Arg: ast.TypeVar("t1"),
+									Ret: ast.TypeVar("t1"),
+								},
+								Node: ast.FuncLit{
+									Arg: "x",
+									Body: ast.Expr{
+										Type: ast.TypeVar("t1"),
+										Node: ast.Ident("x"),
+									},
+								},
+							},
+						},
+					},
+					Expr: ast.Expr{
+						Type: ast.TupleSpec{
+							ast.Primitive("int"),
+							ast.Primitive("string"),
+						},
+						Node: ast.TupleLit{
+							ast.Expr{
+								Type: ast.Primitive("int"),
+								Node: ast.Call{
+									Fn: ast.Expr{
+										Type: ast.FuncSpec{
+											Arg: ast.Primitive("int"),
+											Ret: ast.Primitive("int"),
+										},
+										Node: ast.Ident("id"),
+									},
+									Arg: ast.Expr{
+										Type: ast.Primitive("int"),
+										Node: ast.IntLit(1),
+									},
+								},
+							},
+							ast.Expr{
+								Type: ast.Primitive("string"),
+								Node: ast.Call{
+									Fn: ast.Expr{
+										Type: ast.FuncSpec{
+											Arg: ast.Primitive("string"),
+											Ret: ast.Primitive("string"),
+										},
+										Node: ast.Ident("id"),
+									},
+									Arg: ast.Expr{
+										Type: ast.Primitive("string"),
+										Node: ast.StringLit("a"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// f -> { let g = f; (g 1, g "a") }
+			// `g` is bound to a lambda argument, so it must not be
+			// generalized.
+			Name: "block-w-let-decl-bound-to-lambda-arg",
+			Env:  Environment{},
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "f",
+				Body: ast.Expr{Node: ast.Block{
+					Stmts: []ast.Stmt{
+						ast.LetDecl{
+							Ident:   "g",
+							Binding: ast.Expr{Node: ast.Ident("f")},
+						},
+					},
+					Expr: ast.Expr{Node: ast.TupleLit{
+						ast.Expr{Node: ast.Call{
+							Fn:  ast.Expr{Node: ast.Ident("g")},
+							Arg: ast.Expr{Node: ast.IntLit(1)},
+						}},
+						ast.Expr{Node: ast.Call{
+							Fn:  ast.Expr{Node: ast.Ident("g")},
+							Arg: ast.Expr{Node: ast.StringLit("a")},
+						}},
+					}},
+				}},
+			}},
+			WantedErr: true,
+		},
+		{
+			// x -> { let y = x * 2; y }
+			// `y`'s binding constrains the type of the lambda's argument.
+			Name: "block-w-let-decl-constraining-lambda-arg",
+			Env:  Operators(),
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: ast.Expr{Node: ast.Block{
+					Stmts: []ast.Stmt{
+						ast.LetDecl{
+							Ident: "y",
 							Binding: ast.Expr{Node: ast.Call{
 								Fn: ast.Expr{Node: ast.Call{
-									Fn:  ast.Expr{Node: ast.Ident("add")},
-									Arg: ast.Expr{Node: ast.IntLit(1)},
+									Fn:  ast.Expr{Node: ast.Ident("*")},
+									Arg: ast.Expr{Node: ast.Ident("x")},
 								}},
-								Arg: ast.Expr{Node: ast.IntLit(1)},
+								Arg: ast.Expr{Node: ast.IntLit(2)},
 							}},
 						},
 					},
-					Expr: ast.Expr{
+					Expr: ast.Expr{Node: ast.Ident("y")},
+				}},
+			}},
+			Wanted: ast.Expr{
+				Type: ast.FuncSpec{
+					Arg: ast.Primitive("int"),
+					Ret: ast.Primitive("int"),
+				},
+				Node: ast.FuncLit{
+					Arg: "x",
+					Body: ast.Expr{
 						Type: ast.Primitive("int"),
-						Node: ast.Ident("y"),
+						Node: ast.Block{
+							Stmts: []ast.Stmt{
+								ast.LetDecl{
+									Ident: "y",
+									Binding: ast.Expr{
+										Type: ast.Primitive("int"),
+										Node: ast.Call{
+											Fn: ast.Expr{
+												Type: intToInt,
+												Node: ast.Call{
+													Fn: ast.Expr{
+														Type: ast.FuncSpec{
+															Arg: ast.Primitive(
+																"int",
+															),
+															Ret: intToInt,
+														},
+														Node: ast.Ident("*"),
+													},
+													Arg: ast.Expr{
+														Type: ast.Primitive(
+															"int",
+														),
+														Node: ast.Ident("x"),
+													},
+												},
+											},
+											Arg: ast.Expr{
+												Type: ast.Primitive("int"),
+												Node: ast.IntLit(2),
+											},
+										},
+									},
+								},
+							},
+							Expr: ast.Expr{
+								Type: ast.Primitive("int"),
+								Node: ast.Ident("y"),
+							},
+						},
 					},
 				},
 			},
 		},
+		{
+			// { let y : string = 1; y }
+			Name: "block-w-annotated-let-decl-mismatch",
+			Env:  Environment{},
+			Input: ast.Expr{Node: ast.Block{
+				Stmts: []ast.Stmt{
+					ast.LetDecl{
+						Ident:   "y",
+						Type:    ast.TypeRef{Name: "string"},
+						Binding: ast.Expr{Node: ast.IntLit(1)},
+					},
+				},
+				Expr: ast.Expr{Node: ast.Ident("y")},
+			}},
+			WantedErr: true,
+		},
+		{
+			// { f "a"; 1 }
+			Name: "block-w-ill-typed-expr-stmt",
+			Env: Environment{"f": Mono(ast.FuncSpec{
+				Arg: ast.Primitive("int"),
+				Ret: ast.Primitive("int"),
+			})},
+			Input: ast.Expr{Node: ast.Block{
+				Stmts: []ast.Stmt{
+					ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("f")},
+						Arg: ast.Expr{Node: ast.StringLit("a")},
+					}},
+				},
+				Expr: ast.Expr{Node: ast.IntLit(1)},
+			}},
+			WantedErr: true,
+		},
+		{
+			// f -> f f
+			Name: "func-lit-self-application",
+			Env:  Environment{},
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "f",
+				Body: ast.Expr{Node: ast.Call{
+					Fn:  ast.Expr{Node: ast.Ident("f")},
+					Arg: ast.Expr{Node: ast.Ident("f")},
+				}},
+			}},
+			WantedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -233,6 +611,9 @@ func TestInfer(t *testing.T) {
 				}
 				return
 			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
 			if !got.Equal(testCase.Wanted) {
 				t.Fatalf(
 					"WANTED:\n%# v\n\nGOT:\n%# v\n",
@@ -243,3 +624,115 @@ func TestInfer(t *testing.T) {
 		})
 	}
 }
+
+func TestInferIf(t *testing.T) {
+	env := Environment{
+		"b": Mono(ast.Primitive("bool")),
+		"n": Mono(ast.Primitive("int")),
+	}
+	ifExpr := func(cond, then, els string) ast.Expr {
+		return ast.Expr{Node: ast.If{
+			Cond: ast.Expr{Node: ast.Ident(cond)},
+			Then: ast.Expr{Node: ast.Ident(then)},
+			Else: ast.Expr{Node: ast.Ident(els)},
+		}}
+	}
+
+	testCases := []struct {
+		Name      string
+		Input     ast.Expr
+		Wanted    ast.Type
+		WantedErr bool
+	}{
+		{
+			Name:   "branches-agree",
+			Input:  ifExpr("b", "n", "n"),
+			Wanted: ast.Primitive("int"),
+		},
+		{
+			Name: "branch-lambdas",
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: ast.Expr{Node: ast.If{
+					Cond: ast.Expr{Node: ast.Ident("b")},
+					Then: ast.Expr{Node: ast.Ident("x")},
+					Else: ast.Expr{Node: ast.Ident("n")},
+				}},
+			}},
+			Wanted: ast.FuncSpec{
+				Arg: ast.Primitive("int"),
+				Ret: ast.Primitive("int"),
+			},
+		},
+		{
+			Name:      "cond-not-bool",
+			Input:     ifExpr("n", "n", "n"),
+			WantedErr: true,
+		},
+		{
+			Name:      "branches-disagree",
+			Input:     ifExpr("b", "n", "b"),
+			WantedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			got, err := Infer(env, testCase.Input)
+			if err != nil {
+				if !testCase.WantedErr {
+					t.Fatal("Unexpected error:", err)
+				}
+				return
+			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got type %v", got.Type)
+			}
+			if !got.Type.EqualType(testCase.Wanted) {
+				t.Fatalf("Wanted type %v; got %v", testCase.Wanted, got.Type)
+			}
+		})
+	}
+}
+
+func TestInferConcurrent(t *testing.T) {
+	env := Environment{}
+	input := ast.Expr{Node: ast.FuncLit{
+		Arg: "x",
+		Body: ast.Expr{Node: ast.FuncLit{
+			Arg:  "y",
+			Body: ast.Expr{Node: ast.Ident("x")},
+		}},
+	}}
+	wanted, err := Infer(env, input)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	results := make(chan ast.Expr, 8)
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			got, err := Infer(env, input)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- got
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		select {
+		case err := <-errs:
+			t.Fatal("Unexpected error:", err)
+		case got := <-results:
+			if !got.Equal(wanted) {
+				t.Fatalf(
+					"WANTED:\n%# v\n\nGOT:\n%# v\n",
+					pretty.Formatter(wanted),
+					pretty.Formatter(got),
+				)
+			}
+		}
+	}
+}
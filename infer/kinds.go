@@ -0,0 +1,129 @@
+package infer
+
+import (
+	"fmt"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// Types have kinds, much as values have types. The types of values (`int`,
+// `(a, b)`, `Option int`, etc) have kind `*` and a type declared with `n`
+// parameters has kind `* -> ... -> *` with `n` arrows, i.e., it's a type
+// constructor which makes a type of kind `*` from `n` types of kind `*`.
+// Since type parameters always have kind `*` (there are no higher-kinded
+// types), a type's kind is just the number of arguments it takes, so kind
+// checking amounts to checking that every type is applied to exactly as many
+// arguments as it takes.
+
+// checkDecl returns an error unless the type declared by `decl` is
+// well-kinded: its parameters must be distinct and its type must be a
+// well-kinded type in which the only type variables are its parameters (see
+// checkKind).
+func (r *resolver) checkDecl(decl *ast.TypeDecl) error {
+	for i, param := range decl.Args {
+		if ContainsTypeVar(decl.Args[:i], param) {
+			return TypeError{
+				Span: decl.Span,
+				Err:  fmt.Errorf("Duplicate type parameter: %v", param),
+			}
+		}
+	}
+	if err := r.checkKind(decl.Type, decl.Args, true); err != nil {
+		return TypeError{
+			Span: decl.Span,
+			Err:  fmt.Errorf("In type '%s': %v", decl.Name, err),
+		}
+	}
+	return nil
+}
+
+// checkKind returns an error unless `t` has kind `*`, i.e., unless each type
+// ref in `t` names a primitive, a declared type or one of `params` and is
+// applied to as many arguments as that type takes. If `closed` is true, the
+// type variables in `t` must be among `params` (as in a type declaration);
+// otherwise they're free (as in an annotation, where they're generalized).
+// Row variables aren't type parameters (they stand for fields, not types),
+// so a closed type may not have open records.
+func (r *resolver) checkKind(
+	t ast.Type,
+	params []ast.TypeVar,
+	closed bool,
+) error {
+	switch typ := t.(type) {
+	case nil, ast.Primitive:
+		return nil
+	case ast.TypeVar:
+		if closed && !ContainsTypeVar(params, typ) {
+			return fmt.Errorf("Unbound type variable: %v", typ)
+		}
+		return nil
+	case ast.TypeRef:
+		arity := 0
+		if ContainsTypeVar(params, ast.TypeVar(typ.Name)) {
+			if len(typ.Args) > 0 {
+				return fmt.Errorf(
+					"Type parameter '%s' can't take arguments "+
+						"(higher-kinded types aren't supported)",
+					typ.Name,
+				)
+			}
+		} else if decl, found := r.decls[typ.Name]; found {
+			arity = len(decl.Args)
+		} else if !primitives[typ.Name] {
+			return fmt.Errorf("Unknown type: '%s'", typ.Name)
+		}
+		if len(typ.Args) != arity {
+			return fmt.Errorf(
+				"Type '%s' takes %d argument(s); got %d",
+				typ.Name,
+				arity,
+				len(typ.Args),
+			)
+		}
+		for _, arg := range typ.Args {
+			if err := r.checkKind(arg, params, closed); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.FuncSpec:
+		if err := r.checkKind(typ.Arg, params, closed); err != nil {
+			return err
+		}
+		return r.checkKind(typ.Ret, params, closed)
+	case ast.TupleSpec:
+		for _, t := range typ {
+			if err := r.checkKind(t, params, closed); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.RecordSpec:
+		if closed && typ.IsOpen() {
+			return fmt.Errorf("Unbound row variable: %v", typ.Rest)
+		}
+		seen := map[string]string{}
+		for _, f := range typ.Fields {
+			if err := checkFieldName(seen, f.Name); err != nil {
+				return err
+			}
+			if err := r.checkKind(f.Type, params, closed); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.SumSpec:
+		for _, v := range typ {
+			if err := r.checkKind(v.Type, params, closed); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		panic(fmt.Sprintf(
+			"checkKind() not implemented for %# v",
+			pretty.Formatter(t),
+		))
+	}
+}
@@ -0,0 +1,169 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestKinds(t *testing.T) {
+	ref := func(name string, args ...ast.Type) ast.TypeRef {
+		return ast.TypeRef{Name: name, Args: args}
+	}
+	at := func(line int) ast.Span {
+		return ast.Span{Start: ast.Position{Line: line, Column: 1}}
+	}
+	pair := ast.TypeDecl{
+		Name: "Pair",
+		Type: ast.TupleSpec{ref("a"), ref("b")},
+		Args: []ast.TypeVar{"a", "b"},
+		Span: at(1),
+	}
+	option := ast.TypeDecl{
+		Name: "Option",
+		Type: ast.SumSpec{{Name: "None"}, {Name: "Some", Type: ref("a")}},
+		Args: []ast.TypeVar{"a"},
+		Span: at(2),
+	}
+	decl := func(name string, t ast.Type, args ...ast.TypeVar) ast.TypeDecl {
+		return ast.TypeDecl{Name: name, Type: t, Args: args, Span: at(3)}
+	}
+
+	testCases := []struct {
+		Name      string
+		Stmts     []ast.Stmt
+		WantedErr string
+	}{
+		{
+			Name: "well-kinded",
+			Stmts: []ast.Stmt{
+				pair,
+				option,
+				decl(
+					"Table",
+					ast.FuncSpec{
+						Arg: ref("k"),
+						Ret: ref("Option", ref("Pair", ref("k"), ref("v"))),
+					},
+					"k",
+					"v",
+				),
+				decl("Box", ast.NewRecordSpec([]ast.Field{
+					{Name: "value", Type: ast.TypeVar("a")},
+				}), "a"),
+			},
+		},
+		{
+			Name:      "too-few-arguments",
+			Stmts:     []ast.Stmt{pair, decl("P", ref("Pair", ref("int")))},
+			WantedErr: "3:1: In type 'P': Type 'Pair' takes 2 argument(s)",
+		},
+		{
+			Name: "unapplied-argument",
+			Stmts: []ast.Stmt{
+				option,
+				decl("O", ref("Option", ref("Option"))),
+			},
+			WantedErr: "3:1: In type 'O': Type 'Option' takes 1 argument(s)",
+		},
+		{
+			Name: "applied-parameter",
+			Stmts: []ast.Stmt{
+				decl("Apply", ref("f", ref("int")), "f"),
+			},
+			WantedErr: "3:1: In type 'Apply': Type parameter 'f' can't take " +
+				"arguments",
+		},
+		{
+			Name: "unbound-variable",
+			Stmts: []ast.Stmt{
+				decl("P", ast.TupleSpec{ref("a"), ast.TypeVar("b")}, "a"),
+			},
+			WantedErr: "3:1: In type 'P': Unbound type variable: 'b",
+		},
+		{
+			Name: "unbound-row-variable",
+			Stmts: []ast.Stmt{
+				decl("R", ast.RecordSpec{
+					Fields: []ast.Field{{Name: "a", Type: ref("int")}},
+					Rest:   "r",
+				}),
+			},
+			WantedErr: "3:1: In type 'R': Unbound row variable: 'r",
+		},
+		{
+			Name: "fields-with-the-same-go-name",
+			Stmts: []ast.Stmt{
+				decl("R", ast.NewRecordSpec([]ast.Field{
+					{Name: "name", Type: ref("int")},
+					{Name: "Name", Type: ref("int")},
+				})),
+			},
+			WantedErr: "3:1: In type 'R': Fields 'Name' and 'name' have the " +
+				"same Go name 'Name'",
+		},
+		{
+			Name: "unknown-type",
+			Stmts: []ast.Stmt{
+				decl("P", ast.TupleSpec{ref("a"), ref("b")}, "a"),
+			},
+			WantedErr: "3:1: In type 'P': Unknown type: 'b'",
+		},
+		{
+			Name:      "duplicate-parameter",
+			Stmts:     []ast.Stmt{decl("P", ref("a"), "a", "a")},
+			WantedErr: "3:1: Duplicate type parameter: 'a",
+		},
+		{
+			Name: "annotation",
+			Stmts: []ast.Stmt{
+				option,
+				ast.LetDecl{
+					Ident:   "x",
+					Type:    ref("Option"),
+					Binding: ast.Expr{Node: ast.Ident("None")},
+					Span:    at(4),
+				},
+			},
+			WantedErr: "4:1: Type 'Option' takes 1 argument(s); got 0",
+		},
+		{
+			Name: "annotation-in-expression",
+			Stmts: []ast.Stmt{
+				ast.Expr{
+					Node: ast.FuncLit{
+						Arg:     "x",
+						ArgType: ref("int", ref("int")),
+						Body:    ast.Expr{Node: ast.Ident("x")},
+					},
+					Span: at(5),
+				},
+			},
+			WantedErr: "5:1: Type 'int' takes 0 argument(s); got 1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{Package: "main", Stmts: testCase.Stmts}
+			_, err := File(Environment{}, input)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatal("Wanted an error; got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package infer
+
+import (
+	"fmt"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// constructor returns the variant which `ident` constructs if it's bound to
+// the constructor of a sum type in `env`.
+func constructor(env Environment, ident ast.Ident) (ast.Variant, bool) {
+	s, found := env[ident]
+	if !found {
+		return ast.Variant{}, false
+	}
+	t := s.Type
+	if fs, ok := t.(ast.FuncSpec); ok {
+		t = fs.Ret
+	}
+	ref, ok := t.(ast.TypeRef)
+	if !ok {
+		return ast.Variant{}, false
+	}
+	return ref.Variants().Variant(string(ident))
+}
+
+// AnnotatePattern annotates `p` and its sub-patterns with type variables (or
+// their types, if they're known) like AnnotateExpr. The variables bound by
+// the pattern are added to `bound`. A variable pattern which names a
+// constructor in `env` is a constructor pattern; e.g., in `None -> 0`, `None`
+// matches the `None` constructor rather than binding a variable.
+func AnnotatePattern(
+	p ast.Pattern,
+	env Environment,
+	bound Environment,
+	supply *Supply,
+) (ast.Pattern, error) {
+	switch node := p.Node.(type) {
+	case ast.WildcardPattern:
+		return ast.Pattern{Type: supply.Fresh(), Node: node, Span: p.Span}, nil
+	case ast.VarPattern:
+		if _, ok := constructor(env, ast.Ident(node)); ok {
+			return AnnotatePattern(
+				ast.Pattern{
+					Node: ast.CtorPattern{
+						Ctor: ast.Expr{Node: ast.Ident(node), Span: p.Span},
+					},
+					Span: p.Span,
+				},
+				env,
+				bound,
+				supply,
+			)
+		}
+		if _, found := bound[ast.Ident(node)]; found {
+			return ast.Pattern{}, TypeError{
+				Span: p.Span,
+				Err:  fmt.Errorf("Duplicate pattern variable: '%s'", node),
+			}
+		}
+		t := supply.Fresh()
+		bound[ast.Ident(node)] = Mono(t)
+		return ast.Pattern{Type: t, Node: node, Span: p.Span}, nil
+	case ast.LitPattern:
+		lit, err := AnnotateExpr(ast.Expr{Node: node.Lit}, env, supply)
+		if err != nil {
+			return ast.Pattern{}, err
+		}
+		return ast.Pattern{Type: lit.Type, Node: node, Span: p.Span}, nil
+	case ast.TuplePattern:
+		out := make(ast.TuplePattern, len(node))
+		ts := make(ast.TupleSpec, len(node))
+		for i, p := range node {
+			var err error
+			out[i], err = AnnotatePattern(p, env, bound, supply)
+			if err != nil {
+				return ast.Pattern{}, err
+			}
+			ts[i] = out[i].Type
+		}
+		return ast.Pattern{Type: ts, Node: out, Span: p.Span}, nil
+	case ast.CtorPattern:
+		name := ast.Ident(node.Name())
+		v, ok := constructor(env, name)
+		if !ok {
+			return ast.Pattern{}, TypeError{
+				Span: node.Ctor.Span,
+				Err:  fmt.Errorf("Unknown constructor: '%s'", name),
+			}
+		}
+		if v.Type == nil && node.Arg.Node != nil {
+			return ast.Pattern{}, TypeError{
+				Span: p.Span,
+				Err: fmt.Errorf(
+					"Constructor '%s' doesn't take an argument",
+					name,
+				),
+			}
+		}
+		if v.Type != nil && node.Arg.Node == nil {
+			return ast.Pattern{}, TypeError{
+				Span: p.Span,
+				Err:  fmt.Errorf("Constructor '%s' takes an argument", name),
+			}
+		}
+		ctor, err := AnnotateExpr(node.Ctor, env, supply)
+		if err != nil {
+			return ast.Pattern{}, err
+		}
+		var arg ast.Pattern
+		if node.Arg.Node != nil {
+			arg, err = AnnotatePattern(node.Arg, env, bound, supply)
+			if err != nil {
+				return ast.Pattern{}, err
+			}
+		}
+		return ast.Pattern{
+			Type: supply.Fresh(),
+			Node: ast.CtorPattern{Ctor: ctor, Arg: arg},
+			Span: p.Span,
+		}, nil
+	default:
+		panic(fmt.Sprintf(
+			"Invalid pattern node: %# v",
+			pretty.Formatter(p.Node),
+		))
+	}
+}
+
+// CollectPattern returns the constraints imposed by an annotated pattern, like
+// CollectExpr. A constructor pattern is typed like a call to the constructor.
+func CollectPattern(p ast.Pattern) []Constraint {
+	switch node := p.Node.(type) {
+	case ast.TuplePattern:
+		var constraints []Constraint
+		for _, p := range node {
+			constraints = append(constraints, CollectPattern(p)...)
+		}
+		return constraints
+	case ast.CtorPattern:
+		if node.Arg.Node == nil {
+			return []Constraint{{node.Ctor.Type, p.Type, p.Span}}
+		}
+		return append(
+			CollectPattern(node.Arg),
+			Constraint{
+				node.Ctor.Type,
+				ast.FuncSpec{Arg: node.Arg.Type, Ret: p.Type},
+				p.Span,
+			},
+		)
+	default:
+		return nil
+	}
+}
+
+// ApplyPattern applies `subs` to the types of `p` and its sub-patterns.
+func ApplyPattern(subs []Substitution, p ast.Pattern) ast.Pattern {
+	out := ast.Pattern{Type: Apply(subs, p.Type), Node: p.Node, Span: p.Span}
+	switch node := p.Node.(type) {
+	case ast.TuplePattern:
+		tp := make(ast.TuplePattern, len(node))
+		for i, p := range node {
+			tp[i] = ApplyPattern(subs, p)
+		}
+		out.Node = tp
+	case ast.CtorPattern:
+		cp := ast.CtorPattern{Ctor: ApplyExpr(subs, node.Ctor)}
+		if node.Arg.Node != nil {
+			cp.Arg = ApplyPattern(subs, node.Arg)
+		}
+		out.Node = cp
+	}
+	return out
+}
+
+// CheckMatches returns an error if any match expression in `expr` is missing
+// cases or has a case which can't be reached because the preceding cases match
+// every value it matches. The types in `expr` must be known, i.e., it must
+// have been inferred.
+func CheckMatches(expr ast.Expr) error {
+	switch node := expr.Node.(type) {
+	case ast.TupleLit:
+		for _, expr := range node {
+			if err := CheckMatches(expr); err != nil {
+				return err
+			}
+		}
+	case ast.Block:
+		for _, stmt := range node.Stmts {
+			var err error
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				err = CheckMatches(x.Binding)
+			case ast.Expr:
+				err = CheckMatches(x)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if node.Expr.Node != nil {
+			return CheckMatches(node.Expr)
+		}
+	case ast.FuncLit:
+		return CheckMatches(node.Body)
+	case ast.Call:
+		if err := CheckMatches(node.Fn); err != nil {
+			return err
+		}
+		return CheckMatches(node.Arg)
+	case ast.If:
+		for _, expr := range []ast.Expr{node.Cond, node.Then, node.Else} {
+			if err := CheckMatches(expr); err != nil {
+				return err
+			}
+		}
+	case ast.RecordLit:
+		for _, f := range node {
+			if err := CheckMatches(f.Value); err != nil {
+				return err
+			}
+		}
+	case ast.Project:
+		return CheckMatches(node.Record)
+	case ast.RecordUpdate:
+		if err := CheckMatches(node.Record); err != nil {
+			return err
+		}
+		for _, f := range node.Fields {
+			if err := CheckMatches(f.Value); err != nil {
+				return err
+			}
+		}
+	case ast.Match:
+		if err := CheckMatches(node.Expr); err != nil {
+			return err
+		}
+		types := []ast.Type{node.Expr.Type}
+		var rows [][]ast.Pattern
+		for _, c := range node.Cases {
+			row := []ast.Pattern{c.Pattern}
+			if !useful(rows, row, types) {
+				return TypeError{
+					Span: c.Pattern.Span,
+					Err:  fmt.Errorf("Unreachable case: '%s'", c.Pattern),
+				}
+			}
+			rows = append(rows, row)
+			if err := CheckMatches(c.Body); err != nil {
+				return err
+			}
+		}
+		if missing, ok := witness(rows, types); ok {
+			return TypeError{
+				Span: expr.Span,
+				Err: fmt.Errorf(
+					"Non-exhaustive match: missing case '%s'",
+					missing[0],
+				),
+			}
+		}
+	}
+	return nil
+}
+
+// The exhaustiveness and reachability checks follow Maranget's "Warnings for
+// pattern matching". The cases of a match are rows of a matrix whose columns
+// are the values being matched: initially a single column for the matched
+// expression. Matching the first column against a constructor (a sum type's
+// constructor, a tuple or a literal) "specializes" the matrix: the rows which
+// can't match the constructor are dropped and the first column is replaced
+// with a column for each of the constructor's arguments.
+
+// head identifies the constructor which a pattern matches, if any. Variables
+// and wildcards don't match a particular constructor.
+type head struct {
+	ctor  string // the name of a sum type's constructor or a literal
+	arity int
+}
+
+func headOf(p ast.Pattern) (head, bool) {
+	switch node := p.Node.(type) {
+	case ast.CtorPattern:
+		if node.Arg.Node == nil {
+			return head{ctor: node.Name()}, true
+		}
+		return head{ctor: node.Name(), arity: 1}, true
+	case ast.TuplePattern:
+		return head{arity: len(node)}, true
+	case ast.LitPattern:
+		return head{ctor: node.Lit.String()}, true
+	default:
+		return head{}, false
+	}
+}
+
+// subPatterns returns the sub-patterns of `p` which match the arguments of
+// `h`.
+func subPatterns(p ast.Pattern, h head) []ast.Pattern {
+	switch node := p.Node.(type) {
+	case ast.CtorPattern:
+		if node.Arg.Node != nil {
+			return []ast.Pattern{node.Arg}
+		}
+	case ast.TuplePattern:
+		return node
+	case ast.LitPattern:
+	default:
+		wildcards := make([]ast.Pattern, h.arity)
+		for i := range wildcards {
+			wildcards[i] = ast.Pattern{Node: ast.WildcardPattern{}}
+		}
+		return wildcards
+	}
+	return nil
+}
+
+// specialize returns the rows which match `h` with their first pattern
+// replaced by the patterns of its arguments.
+func specialize(rows [][]ast.Pattern, h head) [][]ast.Pattern {
+	var out [][]ast.Pattern
+	for _, row := range rows {
+		if rowHead, ok := headOf(row[0]); ok && rowHead != h {
+			continue
+		}
+		out = append(out, append(subPatterns(row[0], h), row[1:]...))
+	}
+	return out
+}
+
+// defaults returns the rows whose first pattern matches any value, without
+// that pattern.
+func defaults(rows [][]ast.Pattern) [][]ast.Pattern {
+	var out [][]ast.Pattern
+	for _, row := range rows {
+		if _, ok := headOf(row[0]); !ok {
+			out = append(out, row[1:])
+		}
+	}
+	return out
+}
+
+// signature returns the heads of the constructors of `t` and the types of
+// their arguments if the constructors are finite, i.e., if `t` is a tuple or
+// a sum type.
+func signature(t ast.Type) ([]head, [][]ast.Type, bool) {
+	switch typ := t.(type) {
+	case ast.TupleSpec:
+		return []head{{arity: len(typ)}}, [][]ast.Type{typ}, true
+	case ast.TypeRef:
+		variants := typ.Variants()
+		if variants == nil {
+			return nil, nil, false
+		}
+		heads := make([]head, len(variants))
+		types := make([][]ast.Type, len(variants))
+		for i, v := range variants {
+			heads[i] = head{ctor: v.Name}
+			if v.Type != nil {
+				heads[i].arity = 1
+				types[i] = []ast.Type{v.Type}
+			}
+		}
+		return heads, types, true
+	default:
+		return nil, nil, false
+	}
+}
+
+// complete returns true if the first column of `rows` has a pattern for
+// each of the constructors in `heads`.
+func complete(rows [][]ast.Pattern, heads []head) bool {
+	for _, h := range heads {
+		found := false
+		for _, row := range rows {
+			if rowHead, ok := headOf(row[0]); ok && rowHead == h {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// useful returns true if there's a value which matches `row` but none of
+// `rows`. `types` are the types of the columns.
+func useful(rows [][]ast.Pattern, row []ast.Pattern, types []ast.Type) bool {
+	if len(row) < 1 {
+		return len(rows) < 1
+	}
+	heads, argTypes, finite := signature(types[0])
+	if h, ok := headOf(row[0]); ok {
+		var types2 []ast.Type
+		for i, sigHead := range heads {
+			if sigHead == h {
+				types2 = argTypes[i]
+			}
+		}
+		return useful(
+			specialize(rows, h),
+			append(subPatterns(row[0], h), row[1:]...),
+			append(append([]ast.Type{}, types2...), types[1:]...),
+		)
+	}
+	if finite && complete(rows, heads) {
+		for i, h := range heads {
+			if useful(
+				specialize(rows, h),
+				append(subPatterns(row[0], h), row[1:]...),
+				append(append([]ast.Type{}, argTypes[i]...), types[1:]...),
+			) {
+				return true
+			}
+		}
+		return false
+	}
+	return useful(defaults(rows), row[1:], types[1:])
+}
+
+// witness returns patterns for a value (one pattern for each of `types`)
+// which matches none of `rows`, if there is such a value.
+func witness(rows [][]ast.Pattern, types []ast.Type) ([]ast.Pattern, bool) {
+	if len(types) < 1 {
+		return nil, len(rows) < 1
+	}
+	heads, argTypes, finite := signature(types[0])
+	if finite && complete(rows, heads) {
+		for i, h := range heads {
+			w, ok := witness(
+				specialize(rows, h),
+				append(append([]ast.Type{}, argTypes[i]...), types[1:]...),
+			)
+			if ok {
+				return append(
+					[]ast.Pattern{construct(h, w[:h.arity])},
+					w[h.arity:]...,
+				), true
+			}
+		}
+		return nil, false
+	}
+
+	w, ok := witness(defaults(rows), types[1:])
+	if !ok {
+		return nil, false
+	}
+	missing := ast.Pattern{Node: ast.WildcardPattern{}}
+	if finite {
+		// pick a constructor which none of the rows match
+		for _, h := range heads {
+			if !complete(rows, []head{h}) {
+				missing = construct(h, subPatterns(missing, h))
+				break
+			}
+		}
+	}
+	return append([]ast.Pattern{missing}, w...), true
+}
+
+// construct returns a pattern which matches the constructor `h` applied to
+// `args`.
+func construct(h head, args []ast.Pattern) ast.Pattern {
+	if h.ctor == "" {
+		return ast.Pattern{Node: ast.TuplePattern(args)}
+	}
+	cp := ast.CtorPattern{Ctor: ast.Expr{Node: ast.Ident(h.ctor)}}
+	if h.arity > 0 {
+		cp.Arg = args[0]
+	}
+	return ast.Pattern{Node: cp}
+}
@@ -0,0 +1,236 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestMatch(t *testing.T) {
+	intRef := ast.TypeRef{Name: "int"}
+	shape := ast.TypeDecl{
+		Name: "Shape",
+		Type: ast.SumSpec{
+			{Name: "Circle", Type: intRef},
+			{Name: "Rect", Type: ast.TupleSpec{intRef, intRef}},
+			{Name: "Point"},
+		},
+	}
+	option := ast.TypeDecl{
+		Name: "Option",
+		Type: ast.SumSpec{
+			{Name: "None"},
+			{Name: "Some", Type: ast.TypeRef{Name: "a"}},
+		},
+		Args: []ast.TypeVar{"a"},
+	}
+
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	intLit := func(i int) ast.Expr { return ast.Expr{Node: ast.IntLit(i)} }
+	wildcard := ast.Pattern{Node: ast.WildcardPattern{}}
+	v := func(name string) ast.Pattern {
+		return ast.Pattern{Node: ast.VarPattern(name)}
+	}
+	lit := func(i int) ast.Pattern {
+		return ast.Pattern{Node: ast.LitPattern{Lit: ast.IntLit(i)}}
+	}
+	tuple := func(ps ...ast.Pattern) ast.Pattern {
+		return ast.Pattern{Node: ast.TuplePattern(ps)}
+	}
+	ctor := func(name string, arg ...ast.Pattern) ast.Pattern {
+		cp := ast.CtorPattern{Ctor: ident(name)}
+		if len(arg) > 0 {
+			cp.Arg = arg[0]
+		}
+		return ast.Pattern{Node: cp}
+	}
+	// match x { p0 -> b0; p1 -> b1; ... } for `x -> ...`
+	match := func(cases ...interface{}) ast.Expr {
+		var cs []ast.Case
+		for i := 0; i < len(cases); i += 2 {
+			cs = append(cs, ast.Case{
+				Pattern: cases[i].(ast.Pattern),
+				Body:    cases[i+1].(ast.Expr),
+			})
+		}
+		return ast.Expr{Node: ast.FuncLit{
+			Arg:  "x",
+			Body: ast.Expr{Node: ast.Match{Expr: ident("x"), Cases: cs}},
+		}}
+	}
+	from := func(arg ast.Type) ast.Type {
+		return ast.FuncSpec{Arg: arg, Ret: ast.Primitive("int")}
+	}
+	shapeRef := ast.TypeRef{Name: "Shape"}
+
+	testCases := []struct {
+		Name      string
+		Binding   ast.Expr
+		Wanted    ast.Type
+		WantedErr string
+	}{
+		{
+			Name: "constructors",
+			Binding: match(
+				ctor("Circle", v("r")), ident("r"),
+				ctor("Rect", tuple(v("w"), wildcard)), ident("w"),
+				ctor("Point"), intLit(0),
+			),
+			Wanted: from(shapeRef),
+		},
+		{
+			Name: "nullary-constructor-as-var",
+			Binding: match(
+				v("Point"), intLit(0),
+				wildcard, intLit(1),
+			),
+			Wanted: from(shapeRef),
+		},
+		{
+			Name: "polymorphic-constructors",
+			Binding: match(
+				ctor("Some", v("y")), ident("y"),
+				ctor("None"), intLit(0),
+			),
+			Wanted: from(ast.TypeRef{
+				Name: "Option",
+				Args: []ast.Type{ast.Primitive("int")},
+			}),
+		},
+		{
+			Name: "literals",
+			Binding: match(
+				tuple(lit(0), v("y")), ident("y"),
+				tuple(wildcard, wildcard), intLit(1),
+			),
+			Wanted: from(ast.TupleSpec{
+				ast.Primitive("int"),
+				ast.Primitive("int"),
+			}),
+		},
+		{
+			Name: "missing-constructor",
+			Binding: match(
+				ctor("Circle", v("r")), ident("r"),
+				ctor("Point"), intLit(0),
+			),
+			WantedErr: "missing case 'Rect _'",
+		},
+		{
+			Name: "missing-nested-constructor",
+			Binding: match(
+				ctor("Some", ctor("Some", wildcard)), intLit(0),
+				ctor("None"), intLit(0),
+			),
+			WantedErr: "missing case 'Some None'",
+		},
+		{
+			Name:      "missing-literal",
+			Binding:   match(lit(0), intLit(0), lit(1), intLit(1)),
+			WantedErr: "missing case '_'",
+		},
+		{
+			Name: "missing-tuple",
+			Binding: match(
+				tuple(ctor("None"), wildcard), intLit(0),
+				tuple(wildcard, ctor("None")), intLit(0),
+			),
+			WantedErr: "missing case '(Some _, Some _)'",
+		},
+		{
+			Name: "unreachable",
+			Binding: match(
+				ctor("Some", v("y")), ident("y"),
+				ctor("None"), intLit(0),
+				ctor("Some", lit(1)), intLit(1),
+			),
+			WantedErr: "Unreachable case: 'Some 1'",
+		},
+		{
+			Name:      "unreachable-after-wildcard",
+			Binding:   match(wildcard, intLit(0), lit(1), intLit(1)),
+			WantedErr: "Unreachable case: '1'",
+		},
+		{
+			Name: "mismatched-patterns",
+			Binding: match(
+				ctor("Point"), intLit(0),
+				ctor("None"), intLit(1),
+			),
+			WantedErr: "Mismatched types",
+		},
+		{
+			Name: "mismatched-bodies",
+			Binding: match(
+				ctor("None"), intLit(0),
+				ctor("Some", v("y")), ast.Expr{Node: ast.StringLit("a")},
+			),
+			WantedErr: "Mismatched types",
+		},
+		{
+			Name: "duplicate-variable",
+			Binding: match(
+				tuple(v("y"), v("y")), ident("y"),
+			),
+			WantedErr: "Duplicate pattern variable: 'y'",
+		},
+		{
+			Name:      "unknown-constructor",
+			Binding:   match(ctor("Square", v("y")), ident("y")),
+			WantedErr: "Unknown constructor: 'Square'",
+		},
+		{
+			Name:      "missing-argument",
+			Binding:   match(ctor("Circle"), intLit(0)),
+			WantedErr: "Constructor 'Circle' takes an argument",
+		},
+		{
+			Name:      "unexpected-argument",
+			Binding:   match(ctor("Point", wildcard), intLit(0)),
+			WantedErr: "Constructor 'Point' doesn't take an argument",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{
+				Package: "main",
+				Stmts: []ast.Stmt{
+					shape,
+					option,
+					ast.LetDecl{Ident: "f", Binding: testCase.Binding},
+				},
+			}
+			got, err := File(Environment{}, input)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
+
+			binding := got.Stmts[2].(ast.LetDecl).Binding
+			if !binding.Type.EqualType(testCase.Wanted) {
+				t.Fatalf(
+					"WANTED:\n%# v\n\nGOT:\n%# v\n",
+					pretty.Formatter(testCase.Wanted),
+					pretty.Formatter(binding.Type),
+				)
+			}
+		})
+	}
+}
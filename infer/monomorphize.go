@@ -0,0 +1,618 @@
+package infer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// Monomorphize returns `f` (which must have been annotated by File) with each
+// polymorphic top-level binding which can't be rendered as a Go generic
+// function replaced by a copy of the binding for each type at which it's
+// used, e.g., if `let name = p -> p.name;` is applied to records of two
+// different types, it's replaced by `name` and `name_1` and each reference to
+// it refers to the copy for the reference's type. This lets code be
+// generated for functions which are polymorphic in their record types, which
+// Go's type parameters can't express, and for polymorphic bindings which
+// aren't functions, since Go has no generic variables. The copies are placed
+// where the original binding was, and the first keeps its doc comment. Such a
+// binding is dropped if it isn't used, since there's no type for which to
+// copy it.
+//
+// Go has no generic closures either, so a polymorphic let in a block (i.e.,
+// one whose type has type variables besides the type parameters of the
+// enclosing top-level function) is copied for each type at which the rest of
+// the block uses it too, and dropped if the rest of the block doesn't use it.
+// Its copies are named `_<ident>_<n>`, which can't clash with other
+// identifiers (see parser.Ident).
+//
+// A type variable which isn't constrained by the binding it occurs in (e.g.,
+// `'a` in `let n = (x -> 1) (y -> y);`) may stand for any type, so it's
+// defaulted to the unit type, or to the empty record type if it's a row
+// variable (see defaults).
+//
+// Other polymorphic functions, e.g., `let id = x -> x;`, are kept (whether or
+// not they're used) so they're rendered as Go generic functions. The type
+// variables of such a function are renamed `a`, `b`, etc. in order of their
+// first occurrence in its type, so its type parameters are `_A`, `_B`, etc.
+// Functions are uncurried when they're rendered, so a generic function whose
+// type has a function type returning a type variable (e.g., `'a -> 'b`) can't
+// be rendered as such at a type which instantiates that variable with a
+// function type, e.g., `let apply = f -> x -> f x;` is rendered as
+// `func apply[_A any, _B any](f func(_A) _B, x _A) _B`, whose instance for
+// `(int -> int -> int) -> int -> int -> int` takes a `func(int) func(int) int`
+// rather than a `func(int, int) int` and returns a `func(int) int` rather than
+// taking a third argument. Such a function is copied (see above) for each of
+// those types at which it's used, and the copies are placed after it.
+func Monomorphize(f ast.File) (ast.File, error) {
+	m := monomorphizer{
+		generic:     map[ast.Ident]ast.Expr{},
+		polymorphic: map[ast.Ident]ast.Expr{},
+		instances:   map[ast.Ident][]instance{},
+		names:       map[ast.Ident]bool{},
+	}
+	for _, stmt := range f.Stmts {
+		if letDecl, ok := stmt.(ast.LetDecl); ok {
+			if len(FreeTypeVars(letDecl.Binding.Type)) < 1 {
+				m.names[letDecl.Ident] = true
+				continue
+			}
+			// the original of a binding which needs copies isn't rendered,
+			// so its first copy is named after it
+			if needsCopies(letDecl.Binding) {
+				m.generic[letDecl.Ident] = letDecl.Binding
+			} else {
+				m.names[letDecl.Ident] = true
+				m.polymorphic[letDecl.Ident] = renameTypeVars(letDecl.Binding)
+			}
+		}
+	}
+
+	stmts := make([]ast.Stmt, len(f.Stmts))
+	for i, stmt := range f.Stmts {
+		switch x := stmt.(type) {
+		case ast.LetDecl:
+			if _, found := m.generic[x.Ident]; found {
+				continue
+			}
+			m.params = nil
+			if binding, found := m.polymorphic[x.Ident]; found {
+				x.Binding = binding
+				m.params = FreeTypeVars(x.Binding.Type)
+			}
+			binding, err := m.rewrite(x.Binding, nil)
+			if err != nil {
+				return ast.File{}, err
+			}
+			x.Binding = binding
+			stmts[i] = x
+		case ast.Expr:
+			m.params = nil
+			expr, err := m.rewrite(x, nil)
+			if err != nil {
+				return ast.File{}, err
+			}
+			stmts[i] = expr
+		default:
+			stmts[i] = stmt
+		}
+	}
+
+	// the instances are only complete once every reference has been
+	// rewritten, since an instance's binding may refer to later instances
+	var out []ast.Stmt
+	for i, stmt := range f.Stmts {
+		letDecl, ok := stmt.(ast.LetDecl)
+		instances := m.instances[letDecl.Ident]
+		if _, found := m.generic[letDecl.Ident]; !ok || !found {
+			// the original keeps the doc comment
+			letDecl.Doc = ""
+			out = append(out, stmts[i])
+			out = append(out, copies(letDecl, instances)...)
+			continue
+		}
+		out = append(out, copies(letDecl, instances)...)
+	}
+	return ast.File{Package: f.Package, Stmts: out, Span: f.Span}, nil
+}
+
+// copies returns the let decls of the copies `instances` of the binding of
+// `letDecl`. Only the first copy has the doc comment of `letDecl`.
+func copies(letDecl ast.LetDecl, instances []instance) []ast.Stmt {
+	var out []ast.Stmt
+	for i, inst := range instances {
+		x := letDecl
+		x.Ident = inst.ident
+		x.Binding = *inst.binding
+		if i > 0 {
+			x.Doc = ""
+		}
+		out = append(out, x)
+	}
+	return out
+}
+
+// instance is a copy of a polymorphic binding for one of the types at which
+// it's used.
+type instance struct {
+	ident   ast.Ident
+	typ     ast.Type
+	binding *ast.Expr
+}
+
+// needsCopies returns true if the polymorphic top-level binding `expr` must be
+// copied for each type at which it's used rather than rendered as a Go
+// generic function, i.e., if it isn't a function literal or its type has an
+// open record type.
+func needsCopies(expr ast.Expr) bool {
+	if _, ok := expr.Node.(ast.FuncLit); !ok {
+		return true
+	}
+	return hasOpenRecord(expr.Type)
+}
+
+func hasOpenRecord(t ast.Type) bool {
+	switch x := t.(type) {
+	case ast.FuncSpec:
+		return hasOpenRecord(x.Arg) || hasOpenRecord(x.Ret)
+	case ast.TupleSpec:
+		for _, t := range x {
+			if hasOpenRecord(t) {
+				return true
+			}
+		}
+	case ast.TypeRef:
+		for _, arg := range x.Args {
+			if hasOpenRecord(arg) {
+				return true
+			}
+		}
+	case ast.RecordSpec:
+		if x.IsOpen() {
+			return true
+		}
+		for _, f := range x.Fields {
+			if hasOpenRecord(f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reshapes returns true if the generic function of type `generic` can't be
+// rendered as such at the type `t` (see Monomorphize), i.e., if a type
+// variable which a function type in `generic` returns stands for a function
+// type in `t`.
+func reshapes(generic, t ast.Type) bool {
+	switch x := generic.(type) {
+	case ast.FuncSpec:
+		fs := t.(ast.FuncSpec)
+		if _, ok := x.Ret.(ast.TypeVar); ok {
+			if _, ok := fs.Ret.(ast.FuncSpec); ok {
+				return true
+			}
+		}
+		return reshapes(x.Arg, fs.Arg) || reshapes(x.Ret, fs.Ret)
+	case ast.TupleSpec:
+		for i, generic := range x {
+			if reshapes(generic, t.(ast.TupleSpec)[i]) {
+				return true
+			}
+		}
+	case ast.TypeRef:
+		for i, generic := range x.Args {
+			if reshapes(generic, t.(ast.TypeRef).Args[i]) {
+				return true
+			}
+		}
+	case ast.RecordSpec:
+		// both are closed records with the same fields, which are sorted by
+		// name
+		for i, f := range x.Fields {
+			if reshapes(f.Type, t.(ast.RecordSpec).Fields[i].Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renameTypeVars returns the binding `expr` with the type variables of its
+// type renamed `a`, `b`, etc. in order of their first occurrence. They're
+// renamed via temporary names so a variable isn't renamed to the name of
+// another variable which hasn't been renamed yet.
+func renameTypeVars(expr ast.Expr) ast.Expr {
+	var temps, names []Substitution
+	for i, tv := range FreeTypeVars(expr.Type) {
+		temp := ast.TypeVar("_" + strconv.Itoa(i))
+		name := ast.TypeVar("t" + strconv.Itoa(i))
+		if i < 26 {
+			name = ast.TypeVar(rune('a' + i))
+		}
+		temps = append(temps, Substitution{Var: tv, Type: temp})
+		names = append(names, Substitution{Var: temp, Type: name})
+	}
+	return ApplyExpr(names, ApplyExpr(temps, expr))
+}
+
+type monomorphizer struct {
+	generic     map[ast.Ident]ast.Expr
+	polymorphic map[ast.Ident]ast.Expr
+	instances   map[ast.Ident][]instance
+	names       map[ast.Ident]bool
+
+	// params holds the type variables of the top-level binding being
+	// rewritten, which are its type parameters if it's polymorphic
+	params []ast.TypeVar
+
+	// locals counts the copies of polymorphic lets in blocks, which number
+	// them (see instantiateLocal)
+	locals int
+}
+
+// instantiate returns the identifier of the copy of the polymorphic binding
+// `ident` for the type `t`, making the copy if it doesn't exist yet. A copy of
+// a generic function may itself be generic in the type parameters in `t`.
+func (m *monomorphizer) instantiate(
+	ident ast.Ident,
+	t ast.Type,
+) (ast.Ident, error) {
+	for _, inst := range m.instances[ident] {
+		if inst.typ.EqualType(t) {
+			return inst.ident, nil
+		}
+	}
+
+	generic, found := m.generic[ident]
+	if !found {
+		generic = m.polymorphic[ident]
+	}
+	// the binding's type variables are renamed apart from those of `t`,
+	// which may be named the same
+	var fresh []Substitution
+	for i, tv := range FreeTypeVars(generic.Type) {
+		fresh = append(fresh, Substitution{
+			Var:  tv,
+			Type: ast.TypeVar("_" + strconv.Itoa(i)),
+		})
+	}
+	generic = ApplyExpr(fresh, generic)
+	subs, err := UnifyOne(generic.Type, t)
+	if err != nil {
+		return "", err
+	}
+	name := ident
+	for n := 1; m.names[name]; n++ {
+		name = ident + ast.Ident("_"+strconv.Itoa(n))
+	}
+	m.names[name] = true
+
+	// register the instance before rewriting its binding so recursive
+	// references find it
+	binding := new(ast.Expr)
+	m.instances[ident] = append(
+		m.instances[ident],
+		instance{ident: name, typ: t, binding: binding},
+	)
+	params := m.params
+	m.params = FreeTypeVars(t)
+	*binding, err = m.rewrite(ApplyExpr(subs, generic), nil)
+	m.params = params
+	return name, err
+}
+
+// localLet is a polymorphic let in a block, which is copied for each type at
+// which it's used.
+type localLet struct {
+	binding ast.Expr
+
+	// bound holds the local variables in scope of the let
+	bound map[ast.Ident]*localLet
+
+	instances []instance
+}
+
+// instantiateLocal returns the identifier of the copy of the polymorphic
+// let `l` of the identifier `ident` for the type `t`, making the copy if it
+// doesn't exist yet.
+func (m *monomorphizer) instantiateLocal(
+	l *localLet,
+	ident ast.Ident,
+	t ast.Type,
+) (ast.Ident, error) {
+	for _, inst := range l.instances {
+		if inst.typ.EqualType(t) {
+			return inst.ident, nil
+		}
+	}
+
+	// the let's type variables (but not the type parameters in scope, which
+	// aren't generalized) are renamed apart from those of `t`
+	var fresh []Substitution
+	for i, tv := range FreeTypeVars(l.binding.Type) {
+		if !ContainsTypeVar(m.params, tv) {
+			fresh = append(fresh, Substitution{
+				Var:  tv,
+				Type: ast.TypeVar("_" + strconv.Itoa(i)),
+			})
+		}
+	}
+	binding := ApplyExpr(fresh, l.binding)
+	subs, err := UnifyOne(binding.Type, t)
+	if err != nil {
+		return "", err
+	}
+	name := ast.Ident("_" + string(ident) + "_" + strconv.Itoa(m.locals))
+	m.locals++
+	if binding, err = m.rewrite(ApplyExpr(subs, binding), l.bound); err != nil {
+		return "", err
+	}
+	l.instances = append(
+		l.instances,
+		instance{ident: name, typ: t, binding: &binding},
+	)
+	return name, nil
+}
+
+// rewrite returns `expr` with each reference to a polymorphic top-level
+// binding which is specialized replaced by a reference to the binding's copy
+// for the reference's type, and likewise for polymorphic lets in blocks.
+// `bound` maps the identifiers of the local variables in scope, which shadow
+// the top-level bindings, to their polymorphic lets, or to nil if they aren't
+// polymorphic lets.
+func (m *monomorphizer) rewrite(
+	expr ast.Expr,
+	bound map[ast.Ident]*localLet,
+) (ast.Expr, error) {
+	var err error
+	expr.Type = Apply(m.defaults(expr.Type), expr.Type)
+	switch node := expr.Node.(type) {
+	case ast.IntLit, ast.StringLit:
+		return expr, nil
+	case ast.Ident:
+		if l, found := bound[node]; found {
+			if l == nil {
+				return expr, nil
+			}
+			ident, err := m.instantiateLocal(l, node, expr.Type)
+			if err != nil {
+				return ast.Expr{}, err
+			}
+			return ast.Expr{Type: expr.Type, Node: ident, Span: expr.Span}, nil
+		}
+		_, found := m.generic[node]
+		generic, polymorphic := m.polymorphic[node]
+		if !found && !polymorphic {
+			return expr, nil
+		}
+		// a copy's type must be concrete
+		if found && len(FreeTypeVars(expr.Type)) > 0 {
+			return ast.Expr{}, ambiguous(node, expr)
+		}
+		if !found && !reshapes(generic.Type, expr.Type) {
+			return expr, nil
+		}
+		ident, err := m.instantiate(node, expr.Type)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		return ast.Expr{Type: expr.Type, Node: ident, Span: expr.Span}, nil
+	case ast.TupleLit:
+		out := make(ast.TupleLit, len(node))
+		for i, expr := range node {
+			if out[i], err = m.rewrite(expr, bound); err != nil {
+				return ast.Expr{}, err
+			}
+		}
+		expr.Node = out
+	case ast.Block:
+		inner := copyScope(bound)
+		stmts := make([]ast.Stmt, len(node.Stmts))
+		lets := make([]*localLet, len(node.Stmts))
+		for i, stmt := range node.Stmts {
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				// lets in blocks aren't recursive, so a let's binding is
+				// rewritten in the scope before it
+				if m.isPolymorphic(x.Binding.Type) {
+					lets[i] = &localLet{binding: x.Binding, bound: inner}
+					inner = copyScope(inner)
+					inner[x.Ident] = lets[i]
+					continue
+				}
+				if x.Binding, err = m.rewrite(x.Binding, inner); err != nil {
+					return ast.Expr{}, err
+				}
+				inner = copyScope(inner)
+				inner[x.Ident] = nil
+				stmts[i] = x
+			case ast.Expr:
+				if stmts[i], err = m.rewrite(x, inner); err != nil {
+					return ast.Expr{}, err
+				}
+			default:
+				stmts[i] = stmt
+			}
+		}
+		if node.Expr, err = m.rewrite(node.Expr, inner); err != nil {
+			return ast.Expr{}, err
+		}
+
+		// the copies of a polymorphic let are only complete once the rest
+		// of the block has been rewritten
+		var out []ast.Stmt
+		for i, stmt := range node.Stmts {
+			if lets[i] == nil {
+				out = append(out, stmts[i])
+				continue
+			}
+			out = append(out, copies(stmt.(ast.LetDecl), lets[i].instances)...)
+		}
+		node.Stmts = out
+		expr.Node = node
+	case ast.FuncLit:
+		inner := copyScope(bound)
+		inner[node.Arg] = nil
+		if node.Body, err = m.rewrite(node.Body, inner); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.Call:
+		if node.Fn, err = m.rewrite(node.Fn, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Arg, err = m.rewrite(node.Arg, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.If:
+		if node.Cond, err = m.rewrite(node.Cond, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Then, err = m.rewrite(node.Then, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Else, err = m.rewrite(node.Else, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.Match:
+		if node.Expr, err = m.rewrite(node.Expr, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		cases := make([]ast.Case, len(node.Cases))
+		for i, c := range node.Cases {
+			c.Pattern = ApplyPattern(m.defaults(c.Pattern.Type), c.Pattern)
+			inner := copyScope(bound)
+			for _, ident := range c.Pattern.Vars() {
+				inner[ident] = nil
+			}
+			if c.Body, err = m.rewrite(c.Body, inner); err != nil {
+				return ast.Expr{}, err
+			}
+			cases[i] = c
+		}
+		node.Cases = cases
+		expr.Node = node
+	case ast.RecordLit:
+		fields, err := m.rewriteFields(node, bound)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = ast.RecordLit(fields)
+	case ast.Project:
+		if node.Record, err = m.rewrite(node.Record, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.RecordUpdate:
+		if node.Record, err = m.rewrite(node.Record, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Fields, err = m.rewriteFields(node.Fields, bound); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	default:
+		panic(fmt.Sprintf(
+			"rewrite() not implemented for %# v",
+			pretty.Formatter(expr.Node),
+		))
+	}
+	return expr, nil
+}
+
+// isPolymorphic returns true if `t` has type variables besides the type
+// parameters in scope.
+func (m *monomorphizer) isPolymorphic(t ast.Type) bool {
+	for _, tv := range FreeTypeVars(t) {
+		if !ContainsTypeVar(m.params, tv) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaults returns the substitutions which default the type variables of `t`
+// besides the type parameters in scope (see Monomorphize). Any other type
+// variable of the binding being rewritten either is a type variable of one of
+// its polymorphic lets, which is only rewritten as copies for the types at
+// which it's used, or isn't constrained by the binding.
+func (m *monomorphizer) defaults(t ast.Type) []Substitution {
+	var rows []ast.TypeVar
+	var visit func(t ast.Type)
+	visit = func(t ast.Type) {
+		switch x := t.(type) {
+		case ast.FuncSpec:
+			visit(x.Arg)
+			visit(x.Ret)
+		case ast.TupleSpec:
+			for _, t := range x {
+				visit(t)
+			}
+		case ast.TypeRef:
+			for _, arg := range x.Args {
+				visit(arg)
+			}
+		case ast.RecordSpec:
+			for _, f := range x.Fields {
+				visit(f.Type)
+			}
+			if x.IsOpen() {
+				rows = append(rows, x.Rest)
+			}
+		}
+	}
+	visit(t)
+
+	var subs []Substitution
+	for _, tv := range FreeTypeVars(t) {
+		if ContainsTypeVar(m.params, tv) {
+			continue
+		}
+		var typ ast.Type = ast.TupleSpec{}
+		if ContainsTypeVar(rows, tv) {
+			typ = ast.NewRecordSpec(nil)
+		}
+		subs = append(subs, Substitution{Var: tv, Type: typ})
+	}
+	return subs
+}
+
+// copyScope returns a copy of the local variables `bound` (see rewrite).
+func copyScope(bound map[ast.Ident]*localLet) map[ast.Ident]*localLet {
+	out := make(map[ast.Ident]*localLet, len(bound)+1)
+	for ident, l := range bound {
+		out[ident] = l
+	}
+	return out
+}
+
+func ambiguous(ident ast.Ident, expr ast.Expr) error {
+	return TypeError{
+		Span: expr.Span,
+		Err: fmt.Errorf(
+			"Can't infer a concrete type for '%s': %v",
+			ident,
+			expr.Type,
+		),
+	}
+}
+
+func (m *monomorphizer) rewriteFields(
+	fields []ast.FieldValue,
+	bound map[ast.Ident]*localLet,
+) ([]ast.FieldValue, error) {
+	out := make([]ast.FieldValue, len(fields))
+	for i, f := range fields {
+		value, err := m.rewrite(f.Value, bound)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = ast.FieldValue{Name: f.Name, Value: value}
+	}
+	return out, nil
+}
@@ -0,0 +1,282 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestMonomorphize(t *testing.T) {
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	call := func(fn string, arg ast.Expr) ast.Expr {
+		return ast.Expr{Node: ast.Call{Fn: ident(fn), Arg: arg}}
+	}
+	let := func(name string, binding ast.Expr) ast.Stmt {
+		return ast.LetDecl{Ident: ast.Ident(name), Binding: binding}
+	}
+	record := func(fields ...ast.FieldValue) ast.Expr {
+		return ast.Expr{Node: ast.RecordLit(fields)}
+	}
+	intLit := ast.Expr{Node: ast.IntLit(1)}
+	stringLit := ast.Expr{Node: ast.StringLit("a")}
+	getName := let("getName", ast.Expr{Node: ast.FuncLit{
+		Arg: "r",
+		Body: ast.Expr{
+			Node: ast.Project{Record: ident("r"), Field: "name"},
+		},
+	}})
+	id := let("id", ast.Expr{Node: ast.FuncLit{Arg: "x", Body: ident("x")}})
+	person := ast.NewRecordSpec([]ast.Field{
+		{Name: "age", Type: ast.Primitive("int")},
+		{Name: "name", Type: ast.Primitive("string")},
+	})
+	personName := ast.FuncSpec{Arg: person, Ret: ast.Primitive("string")}
+	idType := ast.FuncSpec{Arg: ast.TypeVar("a"), Ret: ast.TypeVar("a")}
+	incType := ast.FuncSpec{
+		Arg: ast.Primitive("int"),
+		Ret: ast.Primitive("int"),
+	}
+	named := ast.NewRecordSpec([]ast.Field{
+		{Name: "name", Type: ast.Primitive("int")},
+	})
+
+	testCases := []struct {
+		Name      string
+		Stmts     []ast.Stmt
+		Wanted    map[ast.Ident]ast.Type
+		WantedErr string
+	}{
+		{
+			Name: "record-types",
+			Stmts: []ast.Stmt{
+				getName,
+				let("a", call("getName", record(
+					ast.FieldValue{Name: "name", Value: stringLit},
+					ast.FieldValue{Name: "age", Value: intLit},
+				))),
+				let("b", call("getName", record(
+					ast.FieldValue{Name: "name", Value: intLit},
+				))),
+				let("c", call("getName", ident("p"))),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"getName": personName,
+				"getName_1": ast.FuncSpec{
+					Arg: named,
+					Ret: ast.Primitive("int"),
+				},
+				"a": ast.Primitive("string"),
+				"b": ast.Primitive("int"),
+				"c": ast.Primitive("string"),
+			},
+		},
+		{
+			Name: "polymorphic",
+			Stmts: []ast.Stmt{
+				id,
+				let("twice", ast.Expr{Node: ast.FuncLit{
+					Arg:  "y",
+					Body: call("id", call("id", ident("y"))),
+				}}),
+				let("a", call("twice", intLit)),
+				let("b", call("id", stringLit)),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"id":    idType,
+				"twice": idType,
+				"a":     ast.Primitive("int"),
+				"b":     ast.Primitive("string"),
+			},
+		},
+		{
+			Name: "polymorphic-at-function-types",
+			Stmts: []ast.Stmt{
+				id,
+				let("a", ast.Expr{Node: ast.Call{
+					Fn:  call("id", ident("inc")),
+					Arg: intLit,
+				}}),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"id":   idType,
+				"id_1": ast.FuncSpec{Arg: incType, Ret: incType},
+				"a":    ast.Primitive("int"),
+			},
+		},
+		{
+			Name:  "unused",
+			Stmts: []ast.Stmt{id, let("a", intLit)},
+			Wanted: map[ast.Ident]ast.Type{
+				"id": idType,
+				"a":  ast.Primitive("int"),
+			},
+		},
+		{
+			Name:   "unused-copied",
+			Stmts:  []ast.Stmt{getName, let("a", intLit)},
+			Wanted: map[ast.Ident]ast.Type{"a": ast.Primitive("int")},
+		},
+		{
+			Name: "unused-copied-value",
+			Stmts: []ast.Stmt{
+				id,
+				let("myid", ident("id")),
+				let("a", intLit),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"id": idType,
+				"a":  ast.Primitive("int"),
+			},
+		},
+		{
+			Name: "transitive",
+			Stmts: []ast.Stmt{
+				getName,
+				let("nameOf", ast.Expr{Node: ast.FuncLit{
+					Arg:  "y",
+					Body: call("getName", ident("y")),
+				}}),
+				let("a", call("nameOf", ident("p"))),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"getName": personName,
+				"nameOf":  personName,
+				"a":       ast.Primitive("string"),
+			},
+		},
+		{
+			Name: "shadowed",
+			Stmts: []ast.Stmt{
+				getName,
+				let("f", ast.Expr{Node: ast.FuncLit{
+					Arg:  "getName",
+					Body: call("getName", ident("p")),
+				}}),
+				let("a", call("f", ident("getName"))),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"getName": personName,
+				"f": ast.FuncSpec{
+					Arg: ast.FuncSpec{Arg: person, Ret: ast.TypeVar("a")},
+					Ret: ast.TypeVar("a"),
+				},
+				"a": ast.Primitive("string"),
+			},
+		},
+		{
+			Name: "block",
+			Stmts: []ast.Stmt{
+				getName,
+				let("a", ast.Expr{Node: ast.Block{
+					Stmts: []ast.Stmt{let("b", call("getName", ident("p")))},
+					Expr:  ident("b"),
+				}}),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"getName": personName,
+				"a":       ast.Primitive("string"),
+			},
+		},
+		{
+			Name: "polymorphic-let-in-block",
+			Stmts: []ast.Stmt{
+				let("a", ast.Expr{Node: ast.Block{
+					Stmts: []ast.Stmt{id, let("b", call("id", stringLit))},
+					Expr:  call("id", intLit),
+				}}),
+			},
+			Wanted: map[ast.Ident]ast.Type{"a": ast.Primitive("int")},
+		},
+		{
+			Name: "unused-let-in-block",
+			Stmts: []ast.Stmt{
+				let("a", ast.Expr{Node: ast.Block{
+					Stmts: []ast.Stmt{id},
+					Expr:  intLit,
+				}}),
+			},
+			Wanted: map[ast.Ident]ast.Type{"a": ast.Primitive("int")},
+		},
+		{
+			Name: "unconstrained",
+			Stmts: []ast.Stmt{
+				id,
+				let("a", ast.Expr{Node: ast.Call{
+					Fn:  ast.Expr{Node: ast.FuncLit{Arg: "f", Body: intLit}},
+					Arg: ident("id"),
+				}}),
+			},
+			Wanted: map[ast.Ident]ast.Type{
+				"id": idType,
+				"a":  ast.Primitive("int"),
+			},
+		},
+		{
+			Name: "copied-at-type-parameter",
+			Stmts: []ast.Stmt{
+				getName,
+				let("f", ast.Expr{Node: ast.FuncLit{
+					Arg: "x",
+					Body: call("getName", record(
+						ast.FieldValue{Name: "name", Value: ident("x")},
+					)),
+				}}),
+			},
+			WantedErr: "Can't infer a concrete type for 'getName'",
+		},
+	}
+
+	env := Environment{"p": Mono(person), "inc": Mono(incType)}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			input := ast.File{Package: "main", Stmts: testCase.Stmts}
+			annotated, err := File(env, input)
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+			got, err := Monomorphize(annotated)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatal("Wanted an error; got none")
+			}
+
+			if len(got.Stmts) != len(testCase.Wanted) {
+				t.Fatalf(
+					"Wanted %d bindings; got %d",
+					len(testCase.Wanted),
+					len(got.Stmts),
+				)
+			}
+			for _, stmt := range got.Stmts {
+				letDecl := stmt.(ast.LetDecl)
+				wanted, found := testCase.Wanted[letDecl.Ident]
+				if !found {
+					t.Fatalf("Unexpected binding: '%s'", letDecl.Ident)
+				}
+				if !letDecl.Binding.Type.EqualType(wanted) {
+					t.Fatalf(
+						"Wanted '%s' to be %v; got %v",
+						letDecl.Ident,
+						wanted,
+						letDecl.Binding.Type,
+					)
+				}
+			}
+		})
+	}
+}
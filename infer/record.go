@@ -0,0 +1,137 @@
+package infer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+// annotateFields annotates the values of the fields of a record literal or
+// record update. It's an error for a field to be given more than once or for
+// two fields to have the same Go name.
+func annotateFields(
+	fields []ast.FieldValue,
+	env Environment,
+	supply *Supply,
+) ([]ast.FieldValue, error) {
+	out := make([]ast.FieldValue, len(fields))
+	seen := map[string]string{}
+	for i, f := range fields {
+		if err := checkFieldName(seen, f.Name); err != nil {
+			return nil, TypeError{Span: f.Value.Span, Err: err}
+		}
+		value, err := AnnotateExpr(f.Value, env, supply)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = ast.FieldValue{Name: f.Name, Value: value}
+	}
+	return out, nil
+}
+
+// checkFieldName returns an error if the field `name` has the same Go struct
+// field name (see ast.ExportedName) as one of the fields in `seen`, which maps
+// Go names to field names; otherwise it adds `name` to `seen`. Go names are
+// capitalized, so, e.g., fields `name` and `Name` clash.
+func checkFieldName(seen map[string]string, name string) error {
+	exported := ast.ExportedName(name)
+	switch other, found := seen[exported]; {
+	case !found:
+		seen[exported] = name
+		return nil
+	case other == name:
+		return fmt.Errorf("Duplicate field: '%s'", name)
+	default:
+		return fmt.Errorf(
+			"Fields '%s' and '%s' have the same Go name '%s'",
+			other,
+			name,
+			exported,
+		)
+	}
+}
+
+// unifyRecords unifies the types of the fields which both record types have.
+// A field which only one of them has must belong to the other's row, so the
+// other must be open. Each row variable is bound to a record type of the
+// other's remaining fields, which is closed if the other is closed and which
+// otherwise shares a new row variable with the other's row, e.g., unifying
+// `{a: int | r1}` with `{b: int | r2}` binds `r1` to `{b: int | r3}` and
+// `r2` to `{a: int | r3}`. It's an error for a field which only one of them
+// has to clash with one which only the other has (see checkFieldName).
+func unifyRecords(rs1, rs2 ast.RecordSpec) ([]Substitution, error) {
+	var constraints []Constraint
+	var only1, only2 []ast.Field
+	for _, f := range rs1.Fields {
+		if t, found := rs2.Field(f.Name); found {
+			constraints = append(constraints, Constraint{L: f.Type, R: t})
+		} else {
+			only1 = append(only1, f)
+		}
+	}
+	for _, f := range rs2.Fields {
+		if _, found := rs1.Field(f.Name); !found {
+			only2 = append(only2, f)
+		}
+	}
+
+	seen := map[string]string{}
+	for _, f := range only1 {
+		seen[ast.ExportedName(f.Name)] = f.Name
+	}
+	for _, f := range only2 {
+		if err := checkFieldName(seen, f.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case len(only2) > 0 && (!rs1.IsOpen() || rs1.Rest == rs2.Rest):
+		return nil, fmt.Errorf("Type %v has no field '%s'", rs1, only2[0].Name)
+	case len(only1) > 0 && (!rs2.IsOpen() || rs1.Rest == rs2.Rest):
+		return nil, fmt.Errorf("Type %v has no field '%s'", rs2, only1[0].Name)
+	case rs1.Rest == rs2.Rest:
+	case !rs1.IsOpen():
+		constraints = append(constraints, Constraint{
+			L: rs2.Rest,
+			R: ast.RecordSpec{Fields: only1},
+		})
+	case !rs2.IsOpen():
+		constraints = append(constraints, Constraint{
+			L: rs1.Rest,
+			R: ast.RecordSpec{Fields: only2},
+		})
+	default:
+		rest := rowVar(rs1.Rest, rs2.Rest)
+		constraints = append(
+			constraints,
+			Constraint{L: rs1.Rest, R: ast.RecordSpec{only2, rest}},
+			Constraint{L: rs2.Rest, R: ast.RecordSpec{only1, rest}},
+		)
+	}
+	return Unify(constraints)
+}
+
+// rowVar returns a new row variable whose name is derived from `tvs`.
+// Unification has no supply of fresh type variables, so the new variable's
+// name must be distinct from those of the supply (`t0`, `t1`, etc.) and
+// from those of the row variables derived from other type variables.
+func rowVar(tvs ...ast.TypeVar) ast.TypeVar {
+	names := make([]string, len(tvs))
+	for i, tv := range tvs {
+		names[i] = string(tv)
+	}
+	return ast.TypeVar(strings.Join(names, "_") + "r")
+}
+
+func applyFields(
+	subs []Substitution,
+	fields []ast.FieldValue,
+) []ast.FieldValue {
+	out := make([]ast.FieldValue, len(fields))
+	for i, f := range fields {
+		out[i] = ast.FieldValue{Name: f.Name, Value: ApplyExpr(subs, f.Value)}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestInferRecords(t *testing.T) {
+	str, integer := ast.Primitive("string"), ast.Primitive("int")
+	person := ast.NewRecordSpec([]ast.Field{
+		{Name: "name", Type: str},
+		{Name: "age", Type: integer},
+	})
+	env := Environment{
+		"p": Mono(person),
+		"n": Mono(integer),
+		"b": Mono(ast.Primitive("bool")),
+	}
+	ident := func(name string) ast.Expr {
+		return ast.Expr{Node: ast.Ident(name)}
+	}
+	field := func(name string, value ast.Expr) ast.FieldValue {
+		return ast.FieldValue{Name: name, Value: value}
+	}
+	project := func(record ast.Expr, field string) ast.Expr {
+		return ast.Expr{Node: ast.Project{Record: record, Field: field}}
+	}
+	stringLit := ast.Expr{Node: ast.StringLit("Bob")}
+
+	testCases := []struct {
+		Name      string
+		Input     ast.Expr
+		Wanted    ast.Type
+		WantedErr string
+	}{
+		{
+			Name: "literal",
+			Input: ast.Expr{Node: ast.RecordLit{
+				field("name", stringLit),
+				field("age", ident("n")),
+			}},
+			Wanted: person,
+		},
+		{
+			Name:   "project",
+			Input:  project(ident("p"), "name"),
+			Wanted: str,
+		},
+		{
+			Name: "project-literal",
+			Input: project(
+				ast.Expr{Node: ast.RecordLit{field("x", ident("n"))}},
+				"x",
+			),
+			Wanted: integer,
+		},
+		{
+			Name: "update",
+			Input: ast.Expr{Node: ast.RecordUpdate{
+				Record: ident("p"),
+				Fields: []ast.FieldValue{field("name", stringLit)},
+			}},
+			Wanted: person,
+		},
+		{
+			Name: "literal-unifies-regardless-of-order",
+			Input: ast.Expr{Node: ast.Call{
+				Fn: ast.Expr{Node: ast.FuncLit{
+					Arg: "x",
+					Body: ast.Expr{Node: ast.If{
+						Cond: ast.Expr{Node: ast.Ident("b")},
+						Then: ident("x"),
+						Else: ident("p"),
+					}},
+				}},
+				Arg: ast.Expr{Node: ast.RecordLit{
+					field("age", ident("n")),
+					field("name", stringLit),
+				}},
+			}},
+			Wanted: person,
+		},
+		{
+			Name: "duplicate-field",
+			Input: ast.Expr{Node: ast.RecordLit{
+				field("age", ident("n")),
+				field("age", ident("n")),
+			}},
+			WantedErr: "Duplicate field: 'age'",
+		},
+		{
+			Name: "fields-with-the-same-go-name",
+			Input: ast.Expr{Node: ast.RecordLit{
+				field("name", stringLit),
+				field("Name", stringLit),
+			}},
+			WantedErr: "Fields 'name' and 'Name' have the same Go name 'Name'",
+		},
+		{
+			Name: "project-fields-with-the-same-go-name",
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: ast.Expr{Node: ast.TupleLit{
+					project(ident("x"), "name"),
+					project(ident("x"), "Name"),
+				}},
+			}},
+			WantedErr: "have the same Go name 'Name'",
+		},
+		{
+			Name:      "missing-field",
+			Input:     project(ident("p"), "email"),
+			WantedErr: "has no field 'email'",
+		},
+		{
+			Name:      "not-a-record",
+			Input:     project(ident("n"), "name"),
+			WantedErr: "Type int isn't a record",
+		},
+		{
+			Name: "project-unknown-record-type",
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg:  "x",
+				Body: project(ident("x"), "name"),
+			}},
+			Wanted: ast.FuncSpec{
+				Arg: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "name", Type: ast.TypeVar("t2")},
+					},
+					Rest: "t1r",
+				},
+				Ret: ast.TypeVar("t2"),
+			},
+		},
+		{
+			Name: "update-unknown-record-type",
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: ast.Expr{Node: ast.RecordUpdate{
+					Record: ident("x"),
+					Fields: []ast.FieldValue{field("age", ident("n"))},
+				}},
+			}},
+			Wanted: ast.FuncSpec{
+				Arg: ast.RecordSpec{
+					Fields: []ast.Field{{Name: "age", Type: integer}},
+					Rest:   "t1r",
+				},
+				Ret: ast.RecordSpec{
+					Fields: []ast.Field{{Name: "age", Type: integer}},
+					Rest:   "t1r",
+				},
+			},
+		},
+		{
+			Name: "project-several-fields",
+			Input: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: ast.Expr{Node: ast.If{
+					Cond: project(ident("x"), "ok"),
+					Then: project(ident("x"), "name"),
+					Else: stringLit,
+				}},
+			}},
+			Wanted: ast.FuncSpec{
+				Arg: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "name", Type: str},
+						{Name: "ok", Type: ast.Primitive("bool")},
+					},
+					Rest: "t2r_t1rr",
+				},
+				Ret: str,
+			},
+		},
+		{
+			Name: "project-open-record-w-literal",
+			Input: ast.Expr{Node: ast.Call{
+				Fn: ast.Expr{Node: ast.FuncLit{
+					Arg:  "x",
+					Body: project(ident("x"), "name"),
+				}},
+				Arg: ident("p"),
+			}},
+			Wanted: str,
+		},
+		{
+			Name: "update-mismatch",
+			Input: ast.Expr{Node: ast.RecordUpdate{
+				Record: ident("p"),
+				Fields: []ast.FieldValue{field("age", stringLit)},
+			}},
+			WantedErr: "Mismatched types",
+		},
+		{
+			Name: "update-missing-field",
+			Input: ast.Expr{Node: ast.RecordUpdate{
+				Record: ident("p"),
+				Fields: []ast.FieldValue{field("email", stringLit)},
+			}},
+			WantedErr: "has no field 'email'",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			got, err := Infer(env, testCase.Input)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatalf("Wanted an error; got type %v", got.Type)
+			}
+			if !got.Type.EqualType(testCase.Wanted) {
+				t.Fatalf("Wanted type %v; got %v", testCase.Wanted, got.Type)
+			}
+		})
+	}
+}
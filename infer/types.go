@@ -0,0 +1,427 @@
+package infer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+// primitives are the names of the builtin types.
+var primitives = map[string]bool{"int": true, "string": true, "bool": true}
+
+// resolver resolves the type refs in type declarations and annotations
+// against the declared types.
+type resolver struct {
+	decls map[string]*ast.TypeDecl
+
+	// aliases holds the resolved type of each alias (a declaration of a type
+	// other than a sum type) which has been expanded, and expanding holds
+	// the aliases which are being resolved so recursive aliases are detected
+	aliases   map[string]ast.Type
+	expanding map[string]bool
+
+	// supply renames the type variables of annotations (see rename), and
+	// rigid maps the renamed variables of the annotations of the top-level
+	// binding being resolved to the originals
+	supply *Supply
+	rigid  map[ast.TypeVar]rigidVar
+}
+
+// rigidVar is a type variable of the annotations of a top-level binding. Such
+// a variable stands for any type, so the binding must be polymorphic in it,
+// e.g., `let f : 'a -> 'a = x -> x + 1;` is an error (see checkRigid).
+type rigidVar struct {
+	// name is the variable's name in the annotations, and span is the span
+	// of the first annotation in which it occurs
+	name ast.TypeVar
+	span ast.Span
+}
+
+func newResolver(decls map[string]*ast.TypeDecl, supply *Supply) *resolver {
+	return &resolver{
+		decls:     decls,
+		aliases:   map[string]ast.Type{},
+		expanding: map[string]bool{},
+		supply:    supply,
+	}
+}
+
+// binding returns the resolver for the annotations of a new top-level
+// binding (see rename).
+func (r *resolver) binding() *resolver {
+	out := *r
+	out.rigid = map[ast.TypeVar]rigidVar{}
+	return &out
+}
+
+// rename returns the annotation `t` (of the annotated expression or let
+// statement at `span`) with its type variables renamed to fresh ones, so they
+// don't collide with the type variables which are inferred (e.g., a user's
+// `'t0` isn't the supply's `t0`). A variable is renamed alike wherever it
+// occurs in the annotations of the top-level binding being resolved.
+func (r *resolver) rename(t ast.Type, span ast.Span) ast.Type {
+	if t == nil {
+		return nil
+	}
+	types := map[ast.TypeVar]ast.Type{}
+	for _, tv := range FreeTypeVars(t) {
+		renamed := ast.TypeVar("")
+		for fresh, v := range r.rigid {
+			if v.name == tv {
+				renamed = fresh
+			}
+		}
+		if renamed == "" {
+			renamed = r.supply.Fresh()
+			r.rigid[renamed] = rigidVar{name: tv, span: span}
+		}
+		types[tv] = renamed
+	}
+	return t.Replace(types)
+}
+
+// checkRigid returns an error unless the solution `subs` to the constraints of
+// a binding leaves the type variables `rigid` of its annotations distinct
+// type variables, i.e., unless the binding is as polymorphic as its
+// annotations say. The error is reported against the annotation.
+func checkRigid(
+	subs []Substitution,
+	rigid map[ast.TypeVar]rigidVar,
+) error {
+	vars := make([]ast.TypeVar, 0, len(rigid))
+	names := make(map[ast.TypeVar]ast.Type, len(rigid))
+	for tv, v := range rigid {
+		vars = append(vars, tv)
+		names[tv] = v.name
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i] < vars[j] })
+
+	solved := map[ast.TypeVar]ast.TypeVar{}
+	for _, tv := range vars {
+		v := rigid[tv]
+		t := Apply(subs, tv)
+		// a row variable may be bound to an open record without fields,
+		// which is the same as its row variable
+		if rs, ok := t.(ast.RecordSpec); ok && len(rs.Fields) < 1 {
+			if rs.IsOpen() {
+				t = rs.Rest
+			}
+		}
+		other, ok := t.(ast.TypeVar)
+		if !ok {
+			return TypeError{
+				Span: v.span,
+				Err: fmt.Errorf(
+					"The annotation's type variable %v stands for any type, "+
+						"but it's %v",
+					v.name,
+					t.Replace(names),
+				),
+			}
+		}
+		if name, found := solved[other]; found {
+			return TypeError{
+				Span: v.span,
+				Err: fmt.Errorf(
+					"The annotation's type variables %v and %v stand for "+
+						"any types, but they're the same type",
+					name,
+					v.name,
+				),
+			}
+		}
+		solved[other] = v.name
+	}
+	return nil
+}
+
+// resolveType returns `t` with each type ref which names one of `params`
+// replaced by that type variable, each which names a primitive replaced by
+// that primitive, each which names an alias replaced by the aliased type
+// (with the ref's arguments in place of the alias's parameters), and each
+// which names a sum type linked to its declaration. `t` must be well-kinded
+// (see checkKind).
+func (r *resolver) resolveType(
+	t ast.Type,
+	params []ast.TypeVar,
+) (ast.Type, error) {
+	switch typ := t.(type) {
+	case nil, ast.Primitive, ast.TypeVar:
+		return t, nil
+	case ast.TypeRef:
+		if ContainsTypeVar(params, ast.TypeVar(typ.Name)) {
+			return ast.TypeVar(typ.Name), nil
+		}
+		if primitives[typ.Name] {
+			return ast.Primitive(typ.Name), nil
+		}
+		decl := r.decls[typ.Name]
+		args := make([]ast.Type, len(typ.Args))
+		for i, arg := range typ.Args {
+			resolved, err := r.resolveType(arg, params)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = resolved
+		}
+		if _, ok := decl.Type.(ast.SumSpec); ok {
+			return ast.TypeRef{Name: typ.Name, Decl: decl, Args: args}, nil
+		}
+		aliased, err := r.alias(decl)
+		if err != nil {
+			return nil, err
+		}
+		types := make(map[ast.TypeVar]ast.Type, len(args))
+		for i, param := range decl.Args {
+			types[param] = args[i]
+		}
+		return aliased.Replace(types), nil
+	case ast.FuncSpec:
+		arg, err := r.resolveType(typ.Arg, params)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := r.resolveType(typ.Ret, params)
+		if err != nil {
+			return nil, err
+		}
+		return ast.FuncSpec{Arg: arg, Ret: ret}, nil
+	case ast.TupleSpec:
+		out := make(ast.TupleSpec, len(typ))
+		for i, t := range typ {
+			resolved, err := r.resolveType(t, params)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case ast.RecordSpec:
+		fields := make([]ast.Field, len(typ.Fields))
+		for i, f := range typ.Fields {
+			resolved, err := r.resolveType(f.Type, params)
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = ast.Field{Name: f.Name, Type: resolved}
+		}
+		return ast.RecordSpec{Fields: fields, Rest: typ.Rest}, nil
+	case ast.SumSpec:
+		out := make(ast.SumSpec, len(typ))
+		for i, v := range typ {
+			resolved, err := r.resolveType(v.Type, params)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ast.Variant{Name: v.Name, Type: resolved}
+		}
+		return out, nil
+	default:
+		panic(fmt.Sprintf(
+			"resolveType() not implemented for %# v",
+			pretty.Formatter(t),
+		))
+	}
+}
+
+// alias returns the resolved type of the alias `decl` in terms of its
+// parameters. An alias may not refer to itself (directly or indirectly),
+// since it would expand forever; recursive types must be sum types.
+func (r *resolver) alias(decl *ast.TypeDecl) (ast.Type, error) {
+	if t, found := r.aliases[decl.Name]; found {
+		return t, nil
+	}
+	if r.expanding[decl.Name] {
+		return nil, fmt.Errorf("Recursive type alias: '%s'", decl.Name)
+	}
+	r.expanding[decl.Name] = true
+	t, err := r.resolveType(decl.Type, decl.Args)
+	if err != nil {
+		return nil, err
+	}
+	delete(r.expanding, decl.Name)
+	r.aliases[decl.Name] = t
+	return t, nil
+}
+
+// resolveDecl resolves the type declared by `decl` (see resolveType), which
+// must have been checked by checkDecl.
+func (r *resolver) resolveDecl(decl *ast.TypeDecl) error {
+	var t ast.Type
+	var err error
+	if _, ok := decl.Type.(ast.SumSpec); ok {
+		t, err = r.resolveType(decl.Type, decl.Args)
+	} else {
+		t, err = r.alias(decl)
+	}
+	if err != nil {
+		return TypeError{Span: decl.Span, Err: err}
+	}
+	decl.Type = t
+	return nil
+}
+
+// Constructors returns an environment which binds each constructor of the sum
+// type declared by `decl` to its type. A constructor which takes an argument
+// is a function from that argument to the sum type; the others are values of
+// the sum type. The constructors are polymorphic in the type's parameters,
+// e.g., for `type Option a = Some a | None`, `Some` is `forall 'a. 'a ->
+// Option 'a`. If `decl` doesn't declare a sum type, the environment is empty.
+func Constructors(decl *ast.TypeDecl) Environment {
+	env := Environment{}
+	ss, ok := decl.Type.(ast.SumSpec)
+	if !ok {
+		return env
+	}
+
+	ret := ast.TypeRef{Name: decl.Name, Decl: decl}
+	for _, arg := range decl.Args {
+		ret.Args = append(ret.Args, arg)
+	}
+	for _, v := range ss {
+		var t ast.Type = ret
+		if v.Type != nil {
+			t = ast.FuncSpec{Arg: v.Type, Ret: ret}
+		}
+		env[ast.Ident(v.Name)] = Scheme{Vars: decl.Args, Type: t}
+	}
+	return env
+}
+
+// resolveAnnotations returns `expr` with the type annotations of its function
+// arguments and of the let statements of its blocks resolved (see
+// resolveType).
+func (r *resolver) resolveAnnotations(expr ast.Expr) (ast.Expr, error) {
+	var err error
+	switch node := expr.Node.(type) {
+	case nil, ast.IntLit, ast.StringLit, ast.Ident:
+	case ast.TupleLit:
+		out := make(ast.TupleLit, len(node))
+		for i, expr := range node {
+			if out[i], err = r.resolveAnnotations(expr); err != nil {
+				return ast.Expr{}, err
+			}
+		}
+		expr.Node = out
+	case ast.Block:
+		stmts := make([]ast.Stmt, len(node.Stmts))
+		for i, stmt := range node.Stmts {
+			switch x := stmt.(type) {
+			case ast.LetDecl:
+				stmts[i], err = r.resolveLetDecl(x)
+			case ast.Expr:
+				stmts[i], err = r.resolveAnnotations(x)
+			default:
+				stmts[i] = stmt
+			}
+			if err != nil {
+				return ast.Expr{}, err
+			}
+		}
+		inner, err := r.resolveAnnotations(node.Expr)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = ast.Block{Stmts: stmts, Expr: inner}
+	case ast.FuncLit:
+		if err := r.checkKind(node.ArgType, nil, false); err != nil {
+			return ast.Expr{}, TypeError{Span: expr.Span, Err: err}
+		}
+		if node.ArgType, err = r.resolveType(node.ArgType, nil); err != nil {
+			return ast.Expr{}, TypeError{Span: expr.Span, Err: err}
+		}
+		node.ArgType = r.rename(node.ArgType, expr.Span)
+		if node.Body, err = r.resolveAnnotations(node.Body); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.Call:
+		if node.Fn, err = r.resolveAnnotations(node.Fn); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Arg, err = r.resolveAnnotations(node.Arg); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.If:
+		if node.Cond, err = r.resolveAnnotations(node.Cond); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Then, err = r.resolveAnnotations(node.Then); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Else, err = r.resolveAnnotations(node.Else); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.Match:
+		if node.Expr, err = r.resolveAnnotations(node.Expr); err != nil {
+			return ast.Expr{}, err
+		}
+		cases := make([]ast.Case, len(node.Cases))
+		for i, c := range node.Cases {
+			if c.Body, err = r.resolveAnnotations(c.Body); err != nil {
+				return ast.Expr{}, err
+			}
+			cases[i] = c
+		}
+		node.Cases = cases
+		expr.Node = node
+	case ast.RecordLit:
+		fields, err := r.resolveFields(node)
+		if err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = ast.RecordLit(fields)
+	case ast.Project:
+		if node.Record, err = r.resolveAnnotations(node.Record); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	case ast.RecordUpdate:
+		if node.Record, err = r.resolveAnnotations(node.Record); err != nil {
+			return ast.Expr{}, err
+		}
+		if node.Fields, err = r.resolveFields(node.Fields); err != nil {
+			return ast.Expr{}, err
+		}
+		expr.Node = node
+	default:
+		panic(fmt.Sprintf(
+			"resolveAnnotations() not implemented for %# v",
+			pretty.Formatter(expr.Node),
+		))
+	}
+	return expr, nil
+}
+
+// resolveLetDecl returns `letDecl` with its annotation and the annotations
+// within its binding resolved.
+func (r *resolver) resolveLetDecl(letDecl ast.LetDecl) (ast.LetDecl, error) {
+	if err := r.checkKind(letDecl.Type, nil, false); err != nil {
+		return ast.LetDecl{}, TypeError{Span: letDecl.Span, Err: err}
+	}
+	t, err := r.resolveType(letDecl.Type, nil)
+	if err != nil {
+		return ast.LetDecl{}, TypeError{Span: letDecl.Span, Err: err}
+	}
+	letDecl.Type = r.rename(t, letDecl.Span)
+	letDecl.Binding, err = r.resolveAnnotations(letDecl.Binding)
+	return letDecl, err
+}
+
+func (r *resolver) resolveFields(
+	fields []ast.FieldValue,
+) ([]ast.FieldValue, error) {
+	out := make([]ast.FieldValue, len(fields))
+	for i, f := range fields {
+		value, err := r.resolveAnnotations(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = ast.FieldValue{Name: f.Name, Value: value}
+	}
+	return out, nil
+}
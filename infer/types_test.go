@@ -0,0 +1,179 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kr/pretty"
+	"github.com/weberc2/gallium/ast"
+)
+
+func TestResolveTypes(t *testing.T) {
+	ref := func(name string, args ...ast.Type) ast.TypeRef {
+		return ast.TypeRef{Name: name, Args: args}
+	}
+	intRef := ref("int")
+	intLit := ast.Expr{Node: ast.IntLit(1)}
+	pair := ast.TypeDecl{
+		Name: "Pair",
+		Type: ast.TupleSpec{ref("a"), ref("a")},
+		Args: []ast.TypeVar{"a"},
+	}
+	list := ast.TypeDecl{
+		Name: "List",
+		Type: ast.SumSpec{
+			{Name: "Nil"},
+			{
+				Name: "Cons",
+				Type: ast.TupleSpec{ref("a"), ref("List", ref("a"))},
+			},
+		},
+		Args: []ast.TypeVar{"a"},
+	}
+
+	testCases := []struct {
+		Name      string
+		Decls     []ast.TypeDecl
+		Type      ast.Type
+		Binding   ast.Expr
+		Wanted    ast.Type
+		WantedErr string
+	}{
+		{
+			Name:    "alias",
+			Decls:   []ast.TypeDecl{{Name: "Age", Type: intRef}},
+			Type:    ref("Age"),
+			Binding: intLit,
+			Wanted:  ast.Primitive("int"),
+		},
+		{
+			Name: "alias-of-alias",
+			Decls: []ast.TypeDecl{
+				{Name: "Years", Type: ref("Age")},
+				{Name: "Age", Type: intRef},
+			},
+			Type:    ref("Years"),
+			Binding: intLit,
+			Wanted:  ast.Primitive("int"),
+		},
+		{
+			Name:  "generic-alias",
+			Decls: []ast.TypeDecl{pair},
+			Type:  ref("Pair", intRef),
+			Binding: ast.Expr{
+				Node: ast.TupleLit{intLit, intLit},
+			},
+			Wanted: ast.TupleSpec{ast.Primitive("int"), ast.Primitive("int")},
+		},
+		{
+			Name:  "recursive-sum",
+			Decls: []ast.TypeDecl{list},
+			Type:  ref("List", intRef),
+			Binding: ast.Expr{Node: ast.Call{
+				Fn: ast.Expr{Node: ast.Ident("Cons")},
+				Arg: ast.Expr{Node: ast.TupleLit{
+					intLit,
+					ast.Expr{Node: ast.Ident("Nil")},
+				}},
+			}},
+			Wanted: ast.TypeRef{
+				Name: "List",
+				Args: []ast.Type{ast.Primitive("int")},
+			},
+		},
+		{
+			Name:      "unknown-type",
+			Type:      ref("Age"),
+			Binding:   intLit,
+			WantedErr: "Unknown type: 'Age'",
+		},
+		{
+			Name:      "unknown-type-in-decl",
+			Decls:     []ast.TypeDecl{{Name: "Age", Type: ref("Num")}},
+			Type:      intRef,
+			Binding:   intLit,
+			WantedErr: "Unknown type: 'Num'",
+		},
+		{
+			Name:      "too-few-arguments",
+			Decls:     []ast.TypeDecl{pair},
+			Type:      ref("Pair"),
+			Binding:   intLit,
+			WantedErr: "Type 'Pair' takes 1 argument(s); got 0",
+		},
+		{
+			Name:      "too-many-arguments",
+			Decls:     []ast.TypeDecl{list},
+			Type:      ref("List", intRef, intRef),
+			Binding:   intLit,
+			WantedErr: "Type 'List' takes 1 argument(s); got 2",
+		},
+		{
+			Name:      "primitive-with-arguments",
+			Type:      ref("int", intRef),
+			Binding:   intLit,
+			WantedErr: "Type 'int' takes 0 argument(s); got 1",
+		},
+		{
+			Name: "recursive-alias",
+			Decls: []ast.TypeDecl{
+				{Name: "A", Type: ast.TupleSpec{intRef, ref("B")}},
+				{Name: "B", Type: ref("A")},
+			},
+			Type:      intRef,
+			Binding:   intLit,
+			WantedErr: "Recursive type alias",
+		},
+		{
+			Name: "mismatched-refs",
+			Decls: []ast.TypeDecl{
+				list,
+				{Name: "Unit", Type: ast.SumSpec{{Name: "Unit"}}},
+			},
+			Type:      ref("List", intRef),
+			Binding:   ast.Expr{Node: ast.Ident("Unit")},
+			WantedErr: "Mismatched types",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var stmts []ast.Stmt
+			for _, decl := range testCase.Decls {
+				stmts = append(stmts, decl)
+			}
+			stmts = append(stmts, ast.LetDecl{
+				Ident:   "x",
+				Type:    testCase.Type,
+				Binding: testCase.Binding,
+			})
+			input := ast.File{Package: "main", Stmts: stmts}
+			got, err := File(Environment{}, input)
+			if err != nil {
+				if testCase.WantedErr == "" {
+					t.Fatal("Unexpected error:", err)
+				}
+				if !strings.Contains(err.Error(), testCase.WantedErr) {
+					t.Fatalf(
+						"Wanted error %#v; got %#v",
+						testCase.WantedErr,
+						err.Error(),
+					)
+				}
+				return
+			}
+			if testCase.WantedErr != "" {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
+
+			letDecl := got.Stmts[len(got.Stmts)-1].(ast.LetDecl)
+			if !letDecl.Binding.Type.EqualType(testCase.Wanted) {
+				t.Fatalf(
+					"WANTED:\n%# v\n\nGOT:\n%# v\n",
+					pretty.Formatter(testCase.Wanted),
+					pretty.Formatter(letDecl.Binding.Type),
+				)
+			}
+		})
+	}
+}
@@ -15,21 +15,24 @@ func TestUnifyOne(t *testing.T) {
 		WantedErr bool
 	}{
 		{
-			Name:   "two-matching-primitives",
-			Input:  Constraint{ast.Primitive("int"), ast.Primitive("int")},
+			Name: "two-matching-primitives",
+			Input: Constraint{
+				L: ast.Primitive("int"),
+				R: ast.Primitive("int"),
+			},
 			Wanted: nil,
 		},
 		{
 			Name: "mismatched-primitives",
 			Input: Constraint{
-				ast.Primitive("int"),
-				ast.Primitive("string"),
+				L: ast.Primitive("int"),
+				R: ast.Primitive("string"),
 			},
 			WantedErr: true,
 		},
 		{
 			Name:  "primitive-and-typevar",
-			Input: Constraint{ast.TypeVar("a"), ast.Primitive("int")},
+			Input: Constraint{L: ast.TypeVar("a"), R: ast.Primitive("int")},
 			Wanted: []Substitution{{
 				Var:  ast.TypeVar("a"),
 				Type: ast.Primitive("int"),
@@ -37,12 +40,12 @@ func TestUnifyOne(t *testing.T) {
 		},
 		{
 			Name:   "matching-typevars",
-			Input:  Constraint{ast.TypeVar("a"), ast.TypeVar("a")},
+			Input:  Constraint{L: ast.TypeVar("a"), R: ast.TypeVar("a")},
 			Wanted: nil,
 		},
 		{
 			Name:  "typevar-and-primitive",
-			Input: Constraint{ast.Primitive("int"), ast.TypeVar("a")},
+			Input: Constraint{L: ast.Primitive("int"), R: ast.TypeVar("a")},
 			Wanted: []Substitution{{
 				Var:  ast.TypeVar("a"),
 				Type: ast.Primitive("int"),
@@ -51,24 +54,24 @@ func TestUnifyOne(t *testing.T) {
 		{
 			Name: "identical-concrete-fns",
 			Input: Constraint{
-				ast.FuncSpec{ast.Primitive("int"), ast.Primitive("string")},
-				ast.FuncSpec{ast.Primitive("int"), ast.Primitive("string")},
+				L: ast.FuncSpec{ast.Primitive("int"), ast.Primitive("string")},
+				R: ast.FuncSpec{ast.Primitive("int"), ast.Primitive("string")},
 			},
 			Wanted: nil,
 		},
 		{
 			Name: "identical-generic-fns",
 			Input: Constraint{
-				ast.FuncSpec{ast.TypeVar("a"), ast.Primitive("string")},
-				ast.FuncSpec{ast.TypeVar("a"), ast.Primitive("string")},
+				L: ast.FuncSpec{ast.TypeVar("a"), ast.Primitive("string")},
+				R: ast.FuncSpec{ast.TypeVar("a"), ast.Primitive("string")},
 			},
 			Wanted: nil,
 		},
 		{
 			Name: "one-generic-fn-and-one-concrete-fn",
 			Input: Constraint{
-				ast.FuncSpec{ast.TypeVar("a"), ast.TypeVar("b")},
-				ast.FuncSpec{ast.Primitive("int"), ast.Primitive("int")},
+				L: ast.FuncSpec{ast.TypeVar("a"), ast.TypeVar("b")},
+				R: ast.FuncSpec{ast.Primitive("int"), ast.Primitive("int")},
 			},
 			Wanted: []Substitution{
 				{ast.TypeVar("a"), ast.Primitive("int")},
@@ -78,8 +81,8 @@ func TestUnifyOne(t *testing.T) {
 		{
 			Name: "one-concrete-fn-and-one-generic-fn",
 			Input: Constraint{
-				ast.FuncSpec{ast.Primitive("int"), ast.Primitive("int")},
-				ast.FuncSpec{ast.TypeVar("a"), ast.TypeVar("b")},
+				L: ast.FuncSpec{ast.Primitive("int"), ast.Primitive("int")},
+				R: ast.FuncSpec{ast.TypeVar("a"), ast.TypeVar("b")},
 			},
 			Wanted: []Substitution{
 				{ast.TypeVar("a"), ast.Primitive("int")},
@@ -89,66 +92,66 @@ func TestUnifyOne(t *testing.T) {
 		{
 			Name: "identical-tuple-specs",
 			Input: Constraint{
-				ast.TupleSpec{ast.Primitive("int"), ast.Primitive("int")},
-				ast.TupleSpec{ast.Primitive("int"), ast.Primitive("int")},
+				L: ast.TupleSpec{ast.Primitive("int"), ast.Primitive("int")},
+				R: ast.TupleSpec{ast.Primitive("int"), ast.Primitive("int")},
 			},
 			Wanted: nil,
 		},
 		{
 			Name: "tuple-specs-equal-length-mismatched-types",
 			Input: Constraint{
-				ast.TupleSpec{ast.Primitive("string")},
-				ast.TupleSpec{ast.Primitive("int")},
+				L: ast.TupleSpec{ast.Primitive("string")},
+				R: ast.TupleSpec{ast.Primitive("int")},
 			},
 			WantedErr: true,
 		},
 		{
 			Name: "tuple-specs-mismatched-length",
 			Input: Constraint{
-				ast.TupleSpec{ast.Primitive("string"), ast.Primitive("int")},
-				ast.TupleSpec{ast.Primitive("string")},
+				L: ast.TupleSpec{ast.Primitive("string"), ast.Primitive("int")},
+				R: ast.TupleSpec{ast.Primitive("string")},
 			},
 			WantedErr: true,
 		},
 		{
 			Name: "tuple-specs-identical-generic",
 			Input: Constraint{
-				ast.TupleSpec{ast.TypeVar("a")},
-				ast.TupleSpec{ast.TypeVar("a")},
+				L: ast.TupleSpec{ast.TypeVar("a")},
+				R: ast.TupleSpec{ast.TypeVar("a")},
 			},
 			Wanted: nil,
 		},
 		{
 			Name: "tuple-specs-one-generic-one-concrete",
 			Input: Constraint{
-				ast.TupleSpec{ast.TypeVar("a")},
-				ast.TupleSpec{ast.Primitive("int")},
+				L: ast.TupleSpec{ast.TypeVar("a")},
+				R: ast.TupleSpec{ast.Primitive("int")},
 			},
 			Wanted: []Substitution{{ast.TypeVar("a"), ast.Primitive("int")}},
 		},
 		{
 			Name: "tuple-specs-one-concrete-one-generic",
 			Input: Constraint{
-				ast.TupleSpec{ast.Primitive("int")},
-				ast.TupleSpec{ast.TypeVar("a")},
+				L: ast.TupleSpec{ast.Primitive("int")},
+				R: ast.TupleSpec{ast.TypeVar("a")},
 			},
 			Wanted: []Substitution{{ast.TypeVar("a"), ast.Primitive("int")}},
 		},
 		{
 			Name:   "one-typevar-and-one-tuple-spec",
-			Input:  Constraint{ast.TypeVar("a"), ast.TupleSpec{}},
+			Input:  Constraint{L: ast.TypeVar("a"), R: ast.TupleSpec{}},
 			Wanted: []Substitution{{ast.TypeVar("a"), ast.TupleSpec{}}},
 		},
 		{
 			Name:   "one-tuple-spec-and-one-typevar",
-			Input:  Constraint{ast.TupleSpec{}, ast.TypeVar("a")},
+			Input:  Constraint{L: ast.TupleSpec{}, R: ast.TypeVar("a")},
 			Wanted: []Substitution{{ast.TypeVar("a"), ast.TupleSpec{}}},
 		},
 		{
 			Name: "one-fn-and-one-typevar",
 			Input: Constraint{
-				ast.FuncSpec{ast.TupleSpec{}, ast.TupleSpec{}},
-				ast.TypeVar("a"),
+				L: ast.FuncSpec{ast.TupleSpec{}, ast.TupleSpec{}},
+				R: ast.TypeVar("a"),
 			},
 			Wanted: []Substitution{{
 				ast.TypeVar("a"),
@@ -158,14 +161,155 @@ func TestUnifyOne(t *testing.T) {
 		{
 			Name: "one-typevar-and-one-fn",
 			Input: Constraint{
-				ast.TypeVar("a"),
-				ast.FuncSpec{ast.TupleSpec{}, ast.TupleSpec{}},
+				L: ast.TypeVar("a"),
+				R: ast.FuncSpec{ast.TupleSpec{}, ast.TupleSpec{}},
 			},
 			Wanted: []Substitution{{
 				ast.TypeVar("a"),
 				ast.FuncSpec{ast.TupleSpec{}, ast.TupleSpec{}},
 			}},
 		},
+		{
+			Name: "typevar-occurs-in-fn",
+			Input: Constraint{
+				L: ast.TypeVar("a"),
+				R: ast.FuncSpec{ast.TypeVar("a"), ast.TypeVar("b")},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "records-w-same-fields",
+			Input: Constraint{
+				L: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "age", Type: ast.Primitive("int")},
+					{Name: "name", Type: ast.TypeVar("a")},
+				}},
+				R: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "age", Type: ast.Primitive("int")},
+					{Name: "name", Type: ast.Primitive("string")},
+				}},
+			},
+			Wanted: []Substitution{{
+				Var:  ast.TypeVar("a"),
+				Type: ast.Primitive("string"),
+			}},
+		},
+		{
+			Name: "records-w-different-fields",
+			Input: Constraint{
+				L: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "age", Type: ast.Primitive("int")},
+				}},
+				R: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "size", Type: ast.Primitive("int")},
+				}},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "open-record-w-closed-record",
+			Input: Constraint{
+				L: ast.RecordSpec{
+					Fields: []ast.Field{{Name: "name", Type: ast.TypeVar("a")}},
+					Rest:   "r",
+				},
+				R: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "age", Type: ast.Primitive("int")},
+					{Name: "name", Type: ast.Primitive("string")},
+				}},
+			},
+			Wanted: []Substitution{
+				{Var: "a", Type: ast.Primitive("string")},
+				{Var: "r", Type: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "age", Type: ast.Primitive("int")},
+				}}},
+			},
+		},
+		{
+			Name: "open-record-w-closed-record-missing-field",
+			Input: Constraint{
+				L: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "email", Type: ast.TypeVar("a")},
+					},
+					Rest: "r",
+				},
+				R: ast.RecordSpec{Fields: []ast.Field{
+					{Name: "name", Type: ast.Primitive("string")},
+				}},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "open-records",
+			Input: Constraint{
+				L: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "a", Type: ast.Primitive("int")},
+					},
+					Rest: "r1",
+				},
+				R: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "b", Type: ast.Primitive("int")},
+					},
+					Rest: "r2",
+				},
+			},
+			Wanted: []Substitution{
+				{Var: "r1", Type: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "b", Type: ast.Primitive("int")},
+					},
+					Rest: "r1_r2r",
+				}},
+				{Var: "r2", Type: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "a", Type: ast.Primitive("int")},
+					},
+					Rest: "r1_r2r",
+				}},
+			},
+		},
+		{
+			Name: "open-records-w-same-row",
+			Input: Constraint{
+				L: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "a", Type: ast.Primitive("int")},
+					},
+					Rest: "r",
+				},
+				R: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "b", Type: ast.Primitive("int")},
+					},
+					Rest: "r",
+				},
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "open-record-w-non-record",
+			Input: Constraint{
+				L: ast.RecordSpec{
+					Fields: []ast.Field{
+						{Name: "a", Type: ast.Primitive("int")},
+					},
+					Rest: "r",
+				},
+				R: ast.Primitive("int"),
+			},
+			WantedErr: true,
+		},
+		{
+			Name: "typevar-occurs-in-tuple-spec",
+			Input: Constraint{
+				L: ast.TupleSpec{ast.Primitive("int"), ast.TypeVar("a")},
+				R: ast.TypeVar("a"),
+			},
+			WantedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -177,6 +321,9 @@ func TestUnifyOne(t *testing.T) {
 				}
 				t.Fatal("Unexpected error:", err)
 			}
+			if testCase.WantedErr {
+				t.Fatalf("Wanted an error; got %# v", pretty.Formatter(got))
+			}
 
 			if len(testCase.Wanted) != len(got) {
 				t.Fatalf(
@@ -8,6 +8,7 @@ import (
 	"github.com/weberc2/gallium/ast"
 	"github.com/weberc2/gallium/codegen"
 	"github.com/weberc2/gallium/combinator"
+	"github.com/weberc2/gallium/diagnostics"
 	"github.com/weberc2/gallium/infer"
 	"github.com/weberc2/gallium/parser"
 )
@@ -24,37 +25,36 @@ func main() {
 		os.Exit(-1)
 	}
 
-	result := parser.File(combinator.Input(string(data)))
+	source := diagnostics.Source{Name: os.Args[1], Text: string(data)}
+	result := parser.File(combinator.NewInput(source.Text))
 	if result.Err != nil {
-		fmt.Fprintln(os.Stderr, result.Err)
+		source.Render(os.Stderr, diagnostics.FromResult(result))
 		os.Exit(-1)
 	}
 
-	env := infer.Environment{
-		ast.Ident("add"): ast.FuncSpec{
+	env := infer.Operators()
+	env[ast.Ident("add")] = infer.Mono(ast.FuncSpec{
+		Arg: ast.Primitive("int"),
+		Ret: ast.FuncSpec{
 			Arg: ast.Primitive("int"),
-			Ret: ast.FuncSpec{
-				Arg: ast.Primitive("int"),
-				Ret: ast.Primitive("int"),
-			},
-		},
-		ast.Ident("PrintInt"): ast.FuncSpec{
-			Arg: ast.Primitive("int"),
-			Ret: ast.TupleSpec{},
+			Ret: ast.Primitive("int"),
 		},
+	})
+	env[ast.Ident("PrintInt")] = infer.Mono(ast.FuncSpec{
+		Arg: ast.Primitive("int"),
+		Ret: ast.TupleSpec{},
+	})
+
+	file, err := infer.File(env, result.Value.(ast.File))
+	if err != nil {
+		source.Render(os.Stderr, diagnostics.FromError(err))
+		os.Exit(-1)
 	}
 
-	file := result.Value.(ast.File)
-	for i, stmt := range file.Stmts {
-		if letDecl, ok := stmt.(ast.LetDecl); ok {
-			binding, err := infer.Infer(env, letDecl.Binding)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(-1)
-			}
-			env = env.Add(letDecl.Ident, binding.Type)
-			file.Stmts[i] = ast.LetDecl{Ident: letDecl.Ident, Binding: binding}
-		}
+	file, err = infer.Monomorphize(file)
+	if err != nil {
+		source.Render(os.Stderr, diagnostics.FromError(err))
+		os.Exit(-1)
 	}
 
 	if err := codegen.File(file).Render(os.Stdout); err != nil {
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/weberc2/gallium/combinator"
+)
+
+// nestedParens returns a file with a single let decl whose binding nests
+// `depth` parenthesized calls, e.g., "let x = f (f (f 1));".
+func nestedParens(depth int) string {
+	return "package main\n\nlet x = " +
+		strings.Repeat("f (", depth) + "1" + strings.Repeat(")", depth) +
+		";\n"
+}
+
+// largeFile returns a file with `n` let decls of moderate complexity.
+func largeFile(n int) string {
+	var sb strings.Builder
+	sb.WriteString("package main\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(
+			&sb,
+			"\nlet x%d = {\n    let y = add (f %d) (g \"s\");\n"+
+				"    (x -> add x y, (y, %d))\n};\n",
+			i,
+			i,
+			i,
+		)
+	}
+	return sb.String()
+}
+
+func benchmarkParse(b *testing.B, text string) {
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		if result := File(combinator.NewInput(text)); result.Err != nil {
+			b.Fatal("Unexpected error:", result)
+		}
+	}
+}
+
+// BenchmarkNestedParens and BenchmarkLargeFile report throughput in bytes per
+// second, which should stay roughly constant as the input grows.
+func BenchmarkNestedParens(b *testing.B) {
+	for _, depth := range []int{1, 4, 16, 64, 256} {
+		text := nestedParens(depth)
+		b.Run(fmt.Sprint(depth), func(b *testing.B) {
+			benchmarkParse(b, text)
+		})
+	}
+}
+
+func BenchmarkLargeFile(b *testing.B) {
+	for _, n := range []int{1, 10, 100, 1000} {
+		text := largeFile(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			benchmarkParse(b, text)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/weberc2/gallium/ast"
 	"github.com/weberc2/gallium/combinator"
 )
@@ -22,99 +25,173 @@ func Ref(p *combinator.Parser) combinator.Parser {
 	}
 }
 
+// TypeVar parses a type variable, e.g., `'a`.
+func TypeVar(input combinator.Input) combinator.Result {
+	return combinator.Seq(combinator.Lit('\''), Ident).MapSlice(
+		func(vs []interface{}) interface{} {
+			return ast.TypeVar(vs[1].(ast.Ident))
+		},
+	).Rename("TypeVar")(input)
+}
+
+// TupleSpec parses a parenthesized list of types separated by commas, e.g.,
+// `(int, string)`. A single parenthesized type is just that type (the
+// parentheses only group it, e.g., `(int -> int) -> int`), and `()` is the
+// empty tuple type.
 func TupleSpec(input combinator.Input) combinator.Result {
 	return combinator.Seq(
 		combinator.Lit('('),
-		List(
-			Type,
-			combinator.Seq(
-				combinator.CanWS,
-				combinator.Lit(','),
-				combinator.CanWS,
-			),
-		),
+		combinator.CanWS,
+		combinator.Opt(combinator.Seq(List(Type, comma), combinator.CanWS)),
 		combinator.Lit(')'),
-	).Get(1).MapSlice(
-		func(vs []interface{}) interface{} {
-			ts := make(ast.TupleSpec, len(vs))
-			for i, v := range vs {
-				ts[i] = v.(ast.Type)
+	).MapSlice(func(vs []interface{}) interface{} {
+		if vs[2] == nil {
+			return ast.TupleSpec{}
+		}
+		elts := vs[2].([]interface{})[0].([]interface{})
+		if len(elts) == 1 {
+			return elts[0]
+		}
+		ts := make(ast.TupleSpec, len(elts))
+		for i, v := range elts {
+			ts[i] = v.(ast.Type)
+		}
+		return ts
+	}).Rename("TupleSpec")(input)
+}
+
+// TypeAtom parses a type which may be an argument of a type application
+// without parentheses: a type name, a type variable, a parenthesized type or
+// a record type.
+func TypeAtom(input combinator.Input) combinator.Result {
+	return combinator.Any(
+		Ident.Map(func(v interface{}) interface{} {
+			return ast.TypeRef{Name: string(v.(ast.Ident))}
+		}),
+		TypeVar,
+		TupleSpec,
+		RecordSpec,
+	).Rename("TypeAtom")(input)
+}
+
+// TypeApp parses a type name applied to its arguments, e.g., `Map string
+// int`, or a type atom. An argument which is itself an application must be
+// parenthesized, e.g., `Option (List int)`.
+func TypeApp(input combinator.Input) combinator.Result {
+	return combinator.Any(
+		combinator.Seq(
+			Ident,
+			combinator.OneOrMore(
+				combinator.Seq(combinator.WS, TypeAtom).Get(1),
+			),
+		).MapSlice(func(vs []interface{}) interface{} {
+			argValues := vs[1].([]interface{})
+			args := make([]ast.Type, len(argValues))
+			for i, v := range argValues {
+				args[i] = v.(ast.Type)
 			}
-			return ts
-		},
-	).Wrap()(input)
+			return ast.TypeRef{Name: string(vs[0].(ast.Ident)), Args: args}
+		}),
+		TypeAtom,
+	).Rename("TypeApp")(input)
 }
 
-func TypeExpr(input combinator.Input) combinator.Result {
+// RecordSpec parses a record type, e.g., `{name: string, age: int}`. The
+// last field may be followed by a comma. An open record type's fields are
+// followed by its row variable, e.g., `{name: string | 'r}`. As in a type
+// declaration's parameters, the row variable's quote may be omitted.
+func RecordSpec(input combinator.Input) combinator.Result {
+	field := combinator.Seq(
+		Ident,
+		combinator.CanWS,
+		combinator.Lit(':'),
+		combinator.CanWS,
+		Type,
+	).MapSlice(func(vs []interface{}) interface{} {
+		return ast.Field{
+			Name: string(vs[0].(ast.Ident)),
+			Type: vs[4].(ast.Type),
+		}
+	})
 	return combinator.Seq(
-		combinator.Ident,
-		combinator.Opt(combinator.Seq(combinator.WS, Type).Get(1)),
-	).MapSlice(
-		func(vs []interface{}) interface{} {
-			var arg ast.Type
-			if vs[1] != nil {
-				arg = vs[1].(ast.Type)
+		combinator.Lit('{'),
+		combinator.CanWS,
+		combinator.Opt(
+			combinator.Seq(List(field, comma), trailingComma).Get(0),
+		),
+		combinator.CanWS,
+		combinator.Opt(combinator.Seq(
+			combinator.Lit('|'),
+			combinator.CanWS,
+			combinator.Any(
+				TypeVar,
+				Ident.Map(func(v interface{}) interface{} {
+					return ast.TypeVar(v.(ast.Ident))
+				}),
+			),
+			combinator.CanWS,
+		).Get(2)),
+		combinator.Lit('}'),
+	).MapSlice(func(vs []interface{}) interface{} {
+		var fields []ast.Field
+		if vs[2] != nil {
+			for _, v := range vs[2].([]interface{}) {
+				fields = append(fields, v.(ast.Field))
 			}
-			return ast.TypeRef{Name: vs[0].(string), Arg: arg}
-		},
-	).Wrap()(input)
+		}
+		rs := ast.NewRecordSpec(fields)
+		if vs[4] != nil {
+			rs.Rest = vs[4].(ast.TypeVar)
+		}
+		return rs
+	}).Rename("RecordSpec")(input)
 }
 
+// Type parses a type. Function types are written with arrows, which are
+// right-associative, e.g., `int -> int -> int` is `int -> (int -> int)`.
+// Type application binds more tightly than the arrow, so `Option int -> int`
+// is `(Option int) -> int`.
 func Type(input combinator.Input) combinator.Result {
-	return combinator.Any(FuncSpec, TypeExpr, TupleSpec).Wrap()(input)
-}
-
-// func FuncSpec(input combinator.Input) combinator.Result {
-// 	panic("Fix me")
-// 	// return combinator.Seq(
-// 	// 	combinator.StrLit("fn"), // 0
-// 	// 	combinator.CanWS,        // 1
-// 	// 	combinator.Lit('('),     // 2
-// 	// 	combinator.Opt(List(
-// 	// 		ArgSpec,
-// 	// 		combinator.Seq(
-// 	// 			combinator.CanWS,
-// 	// 			combinator.Lit(','),
-// 	// 			combinator.CanWS,
-// 	// 		),
-// 	// 	)), // 3
-// 	// 	combinator.Lit(')'), // 4
-// 	// 	combinator.Opt(combinator.Seq(
-// 	// 		combinator.CanWS,
-// 	// 		combinator.StrLit("->"),
-// 	// 		combinator.CanWS,
-// 	// 		Type,
-// 	// 	).Get(3)), // 5
-// 	// ).MapSlice(func(vs []interface{}) interface{} {
-// 	// 	var args []ast.ArgSpec
-// 	// 	if vs[3] != nil {
-// 	// 		argValues := vs[3].([]interface{})
-// 	// 		args = make([]ast.ArgSpec, len(argValues))
-// 	// 		for i, v := range argValues {
-// 	// 			args[i] = v.(ast.ArgSpec)
-// 	// 		}
-// 	// 	}
-// 	// 	var ret ast.Type
-// 	// 	if vs[5] != nil {
-// 	// 		ret = vs[5].(ast.Type)
-// 	// 	}
-// 	// 	return ast.FuncSpec{Args: args, Ret: ret}
-// 	// }).Wrap()(input)
-// }
+	return combinator.Seq(
+		TypeApp,
+		combinator.Opt(combinator.Seq(
+			combinator.CanWS,
+			combinator.StrLit("->"),
+			combinator.CanWS,
+			Type,
+		).Get(3)),
+	).MapSlice(func(vs []interface{}) interface{} {
+		if vs[1] == nil {
+			return vs[0]
+		}
+		return ast.FuncSpec{Arg: vs[0].(ast.Type), Ret: vs[1].(ast.Type)}
+	}).Label("type").Rename("Type")(input)
+}
 
+// annotation parses a type annotation, e.g., the `: int` of `x : int`.
+var annotation = combinator.Seq(
+	combinator.Lit(':'),
+	combinator.CanWS,
+	Type,
+	combinator.CanWS,
+).Get(2)
+
+// ArgSpec parses a function argument optionally annotated with its type,
+// e.g., `x : int`.
 func ArgSpec(input combinator.Input) combinator.Result {
 	return combinator.Seq(
-		combinator.Ident,
-		combinator.Opt(combinator.Seq(combinator.WS, Type).Get(1)),
+		Ident,
+		combinator.CanWS,
+		combinator.Opt(annotation),
 	).MapSlice(
 		func(vs []interface{}) interface{} {
 			var typ ast.Type
-			if vs[1] != nil {
-				typ = vs[1].(ast.Type)
+			if vs[2] != nil {
+				typ = vs[2].(ast.Type)
 			}
-			return ast.ArgSpec{Name: vs[0].(string), Type: typ}
+			return ast.ArgSpec{Name: string(vs[0].(ast.Ident)), Type: typ}
 		},
-	).Wrap()(input)
+	).Rename("ArgSpec")(input)
 }
 
 func ExprList(input combinator.Input) combinator.Result {
@@ -132,32 +209,128 @@ func ExprList(input combinator.Input) combinator.Result {
 			tail = vs[1].([]ast.Expr)
 		}
 		return append([]ast.Expr{vs[0].(ast.Expr)}, tail...)
-	}).Wrap()(input)
+	}).Rename("ExprList")(input)
 }
 
-func wrapExpr(v interface{}) interface{} {
-	return ast.Expr{Node: v.(ast.ExprNode)}
+func span(start, end combinator.Position) ast.Span {
+	return ast.Span{Start: ast.Position(start), End: ast.Position(end)}
 }
 
-func Atom(input combinator.Input) combinator.Result {
-	return combinator.Any(
-		ParenGroup,
-		combinator.Parser.Map(TupleLit, wrapExpr),
-		Ident.Map(wrapExpr),
-		IntLit.Map(wrapExpr),
-		StringLit.Map(wrapExpr),
-	).Wrap()(input)
+func wrapExpr(v interface{}, start, end combinator.Position) interface{} {
+	return ast.Expr{Node: v.(ast.ExprNode), Span: span(start, end)}
 }
 
-func Expr(input combinator.Input) combinator.Result {
-	return combinator.Any(
-		combinator.Any(Block, Call, FuncLit).Map(
-			func(v interface{}) interface{} {
-				return ast.Expr{Node: v.(ast.ExprNode)}
-			},
+// Expr and Atom are memoized because nearly every alternative in the grammar
+// begins with one of them, so they're attempted at the same position many
+// times over. They're assigned in init() because they're defined in terms of
+// each other.
+var atom, expr combinator.Parser
+
+func init() {
+	atom = combinator.Memo(parseAtom)
+	expr = combinator.Memo(parseExpr)
+}
+
+func Atom(input combinator.Input) combinator.Result { return atom(input) }
+
+// parseAtom parses an atom optionally followed by the names of the fields
+// it's projected onto, e.g., `p.name`.
+func parseAtom(input combinator.Input) combinator.Result {
+	type project struct {
+		field string
+		end   combinator.Position
+	}
+	return combinator.Seq(
+		combinator.Any(
+			ParenGroup,
+			combinator.Parser.MapSpan(TupleLit, wrapExpr),
+			Ident.MapSpan(wrapExpr),
+			IntLit.MapSpan(wrapExpr),
+			StringLit.MapSpan(wrapExpr),
 		),
-		Atom,
-	).Wrap()(input)
+		combinator.Repeat(combinator.Seq(
+			combinator.Lit('.').Label(""),
+			Ident,
+		).MapSpan(
+			func(v interface{}, start, end combinator.Position) interface{} {
+				field := v.([]interface{})[1].(ast.Ident)
+				return project{field: string(field), end: end}
+			},
+		)),
+	).MapSlice(func(vs []interface{}) interface{} {
+		expr := vs[0].(ast.Expr)
+		for _, v := range vs[1].([]interface{}) {
+			p := v.(project)
+			end := ast.Position(p.end)
+			expr = ast.Expr{
+				Node: ast.Project{Record: expr, Field: p.field},
+				Span: ast.Span{Start: expr.Span.Start, End: end},
+			}
+		}
+		return expr
+	}).Label("expression").Rename("parseAtom")(input)
+}
+
+func Expr(input combinator.Input) combinator.Result { return expr(input) }
+
+// parseExpr parses an expression. Expressions which begin with a brace (record
+// literals, record updates and blocks) aren't atoms, so they must be
+// parenthesized to be passed as arguments; otherwise `match x { ... }` would
+// be ambiguous.
+func parseExpr(input combinator.Input) combinator.Result {
+	return combinator.Any(
+		combinator.Any(
+			RecordLit,
+			RecordUpdate,
+			Block,
+			If,
+			Match,
+			FuncLit,
+		).MapSpan(wrapExpr),
+		Binary,
+	).Label("expression").Rename("parseExpr")(input)
+}
+
+// Operators is the table of infix operators. As in Go, multiplicative
+// operators bind more tightly than additive operators, which bind more tightly
+// than comparisons, which bind more tightly than `&&` and then `||`.
+// Comparisons don't associate, so `a < b < c` is a syntax error.
+var Operators = combinator.OperatorTable{
+	{Symbol: "||", Prec: 1, Assoc: combinator.AssocLeft},
+	{Symbol: "&&", Prec: 2, Assoc: combinator.AssocLeft},
+	{Symbol: "==", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: "!=", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: "<", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: ">", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: "<=", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: ">=", Prec: 3, Assoc: combinator.AssocNone},
+	{Symbol: "+", Prec: 4, Assoc: combinator.AssocLeft},
+	{Symbol: "-", Prec: 4, Assoc: combinator.AssocLeft},
+	{Symbol: "*", Prec: 5, Assoc: combinator.AssocLeft},
+	{Symbol: "/", Prec: 5, Assoc: combinator.AssocLeft},
+	{Symbol: "%", Prec: 5, Assoc: combinator.AssocLeft},
+}
+
+// Binary parses calls and atoms joined by infix operators. `a + b` is
+// desugared into the call `(+ a) b`, so operators are typed like any other
+// function (see ast.Ident.IsOperator).
+func Binary(input combinator.Input) combinator.Result {
+	return combinator.Infix(
+		combinator.Any(combinator.Parser(Call).MapSpan(wrapExpr), Atom),
+		Operators,
+		func(op combinator.Operator, l, r interface{}) interface{} {
+			lhs, rhs := l.(ast.Expr), r.(ast.Expr)
+			span := ast.Span{Start: lhs.Span.Start, End: rhs.Span.End}
+			fn := ast.Expr{Node: ast.Ident(op.Symbol), Span: span}
+			return ast.Expr{
+				Node: ast.Call{
+					Fn:  ast.Expr{Node: ast.Call{Fn: fn, Arg: lhs}, Span: span},
+					Arg: rhs,
+				},
+				Span: span,
+			}
+		},
+	).Rename("Binary")(input)
 }
 
 func ParenGroup(input combinator.Input) combinator.Result {
@@ -167,7 +340,7 @@ func ParenGroup(input combinator.Input) combinator.Result {
 		Expr,
 		combinator.CanWS,
 		combinator.Lit(')'),
-	).Get(2).Wrap()(input)
+	).Get(2).Rename("ParenGroup")(input)
 }
 
 func TupleLit(input combinator.Input) combinator.Result {
@@ -203,23 +376,94 @@ func TupleLit(input combinator.Input) combinator.Result {
 		combinator.CanWS,
 		combinator.Lit(')'),
 	).Map(func(v interface{}) interface{} { return ast.TupleLit{} })
-	return combinator.Any(unit, multi).Wrap()(input)
+	return combinator.Any(unit, multi).Rename("TupleLit")(input)
+}
+
+var (
+	comma = combinator.Seq(
+		combinator.CanWS,
+		combinator.Lit(','),
+		combinator.CanWS,
+	)
+	trailingComma = combinator.Opt(combinator.Seq(
+		combinator.CanWS,
+		combinator.Lit(','),
+	))
+)
+
+// FieldValues parses the comma-separated fields of a record literal or
+// record update, e.g., `name = "Alice", age = 3`. The last field may be
+// followed by a comma.
+func FieldValues(input combinator.Input) combinator.Result {
+	field := combinator.Seq(
+		Ident,
+		combinator.CanWS,
+		combinator.Lit('='),
+		combinator.CanWS,
+		Expr,
+	).MapSlice(func(vs []interface{}) interface{} {
+		return ast.FieldValue{
+			Name:  string(vs[0].(ast.Ident)),
+			Value: vs[4].(ast.Expr),
+		}
+	})
+	return combinator.Seq(List(field, comma), trailingComma).Get(0).MapSlice(
+		func(vs []interface{}) interface{} {
+			fields := make([]ast.FieldValue, len(vs))
+			for i, v := range vs {
+				fields[i] = v.(ast.FieldValue)
+			}
+			return fields
+		},
+	).Rename("FieldValues")(input)
+}
+
+// RecordLit parses a record literal, e.g., `{name = "Alice", age = 3}`.
+func RecordLit(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		combinator.Lit('{'),
+		combinator.CanWS,
+		FieldValues,
+		combinator.CanWS,
+		combinator.Lit('}'),
+	).Get(2).Map(func(v interface{}) interface{} {
+		return ast.RecordLit(v.([]ast.FieldValue))
+	}).Rename("RecordLit")(input)
+}
+
+// RecordUpdate parses a record update, e.g., `{p | age = 4}`.
+func RecordUpdate(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		combinator.Lit('{'), // 0
+		combinator.CanWS,    // 1
+		Expr,                // 2
+		combinator.CanWS,    // 3
+		combinator.Lit('|'), // 4
+		combinator.CanWS,    // 5
+		FieldValues,         // 6
+		combinator.CanWS,    // 7
+		combinator.Lit('}'), // 8
+	).MapSlice(func(vs []interface{}) interface{} {
+		return ast.RecordUpdate{
+			Record: vs[2].(ast.Expr),
+			Fields: vs[6].([]ast.FieldValue),
+		}
+	}).Rename("RecordUpdate")(input)
 }
 
 func Block(input combinator.Input) combinator.Result {
 	return combinator.Seq(
 		combinator.Lit('{'),
+		combinator.Repeat(DocStmt).MapSlice(
+			func(vs []interface{}) interface{} {
+				var stmts []ast.Stmt
+				for _, v := range vs {
+					stmts = append(stmts, v.(ast.Stmt))
+				}
+				return stmts
+			},
+		),
 		combinator.CanWS,
-		combinator.Repeat(combinator.Seq(
-			Stmt,
-			combinator.CanWS,
-		).Get(0)).MapSlice(func(vs []interface{}) interface{} {
-			var stmts []ast.Stmt
-			for _, v := range vs {
-				stmts = append(stmts, v.(ast.Stmt))
-			}
-			return stmts
-		}),
 		combinator.Opt(combinator.Seq(Expr, combinator.CanWS).Get(0)),
 		combinator.Lit('}'),
 	).MapSlice(func(vs []interface{}) interface{} {
@@ -227,101 +471,387 @@ func Block(input combinator.Input) combinator.Result {
 		if vs[3] != nil {
 			expr = vs[3].(ast.Expr)
 		}
-		return ast.Block{Stmts: vs[2].([]ast.Stmt), Expr: expr}
-	}).Wrap()(input)
+		return ast.Block{Stmts: vs[1].([]ast.Stmt), Expr: expr}
+	}).Rename("Block")(input)
 }
 
-func Call(input combinator.Input) combinator.Result {
-	seqToCall := func(vs []interface{}) interface{} {
-		return ast.Call{Fn: vs[0].(ast.Expr), Arg: vs[2].(ast.Expr)}
-	}
-	callToExpr := func(v interface{}) interface{} {
-		return ast.Expr{Node: v.(ast.Call)}
+// Variant parses one of the alternatives of a sum type: a constructor name
+// optionally followed by the type of its argument, e.g., `Circle int`.
+func Variant(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		Ident,
+		combinator.Opt(combinator.Seq(combinator.WS, Type).Get(1)),
+	).MapSlice(func(vs []interface{}) interface{} {
+		v := ast.Variant{Name: string(vs[0].(ast.Ident))}
+		if vs[1] != nil {
+			v.Type = vs[1].(ast.Type)
+		}
+		return v
+	}).Rename("Variant")(input)
+}
+
+// SumSpec parses the variants of a sum type, separated by bars, e.g.,
+// `Circle int | Rect (int, int)`. A sum type has at least one bar; a leading
+// bar allows for declaring a sum type with a single variant (`| Box int`)
+// rather than an alias (`Box int`).
+func SumSpec(input combinator.Input) combinator.Result {
+	bar := combinator.Seq(
+		combinator.CanWS,
+		combinator.Lit('|'),
+		combinator.CanWS,
+	)
+	toSumSpec := func(vs []interface{}) interface{} {
+		ss := make(ast.SumSpec, len(vs))
+		for i, v := range vs {
+			ss[i] = v.(ast.Variant)
+		}
+		return ss
 	}
-	simple := combinator.Seq(Atom, combinator.WS, Atom).MapSlice(seqToCall)
-	complex := combinator.Seq(
-		simple.Map(callToExpr),
-		combinator.WS,
-		Atom,
-	).MapSlice(seqToCall)
-	return combinator.Any(complex, simple).Wrap()(input)
+	return combinator.Any(
+		combinator.Seq(
+			combinator.Lit('|'),
+			combinator.CanWS,
+			List(Variant, bar).MapSlice(toSumSpec),
+		).Get(2),
+		combinator.Seq(
+			Variant,
+			combinator.OneOrMore(combinator.Seq(bar, Variant).Get(1)),
+		).MapSlice(func(vs []interface{}) interface{} {
+			return toSumSpec(append(
+				[]interface{}{vs[0]},
+				vs[1].([]interface{})...,
+			))
+		}),
+	).Rename("SumSpec")(input)
+}
+
+func wrapPattern(v interface{}, start, end combinator.Position) interface{} {
+	return ast.Pattern{Node: v.(ast.PatternNode), Span: span(start, end)}
 }
 
-func FuncSpec(input combinator.Input) combinator.Result {
+// Pattern parses the pattern of a match case: either a constructor applied
+// to a pattern (e.g., `Circle r`) or an atomic pattern.
+func Pattern(input combinator.Input) combinator.Result {
+	return combinator.Any(
+		combinator.Seq(
+			Ident.MapSpan(wrapExpr),
+			combinator.WS,
+			AtomPattern,
+		).MapSpan(func(
+			v interface{},
+			start combinator.Position,
+			end combinator.Position,
+		) interface{} {
+			vs := v.([]interface{})
+			return wrapPattern(
+				ast.CtorPattern{
+					Ctor: vs[0].(ast.Expr),
+					Arg:  vs[2].(ast.Pattern),
+				},
+				start,
+				end,
+			)
+		}),
+		AtomPattern,
+	).Label("pattern").Rename("Pattern")(input)
+}
+
+// AtomPattern parses a pattern which doesn't need parentheses to be the
+// argument of a constructor pattern: `_`, a variable (or a constructor which
+// doesn't take an argument), an int or string literal, a tuple of patterns or
+// a parenthesized pattern.
+func AtomPattern(input combinator.Input) combinator.Result {
+	tuple := combinator.Seq(
+		combinator.Lit('('),
+		combinator.CanWS,
+		combinator.Opt(List(
+			Pattern,
+			combinator.Seq(
+				combinator.CanWS,
+				combinator.Lit(','),
+				combinator.CanWS,
+			),
+		)),
+		combinator.CanWS,
+		combinator.Lit(')'),
+	).MapSpan(func(
+		v interface{},
+		start combinator.Position,
+		end combinator.Position,
+	) interface{} {
+		vs := v.([]interface{})
+		if vs[2] == nil {
+			return wrapPattern(ast.TuplePattern{}, start, end)
+		}
+		elts := vs[2].([]interface{})
+		if len(elts) == 1 {
+			return elts[0]
+		}
+		tp := make(ast.TuplePattern, len(elts))
+		for i, elt := range elts {
+			tp[i] = elt.(ast.Pattern)
+		}
+		return wrapPattern(tp, start, end)
+	})
+	return combinator.Any(
+		tuple,
+		Ident.Map(func(v interface{}) interface{} {
+			if v.(ast.Ident) == "_" {
+				return ast.WildcardPattern{}
+			}
+			return ast.VarPattern(v.(ast.Ident))
+		}).MapSpan(wrapPattern),
+		combinator.Any(IntLit, StringLit).Map(func(v interface{}) interface{} {
+			return ast.LitPattern{Lit: v.(ast.ExprNode)}
+		}).MapSpan(wrapPattern),
+	).Label("pattern").Rename("AtomPattern")(input)
+}
+
+// Case parses a case of a match expression, e.g., `Circle r -> r`.
+func Case(input combinator.Input) combinator.Result {
 	return combinator.Seq(
-		Ident,
+		Pattern,
 		combinator.CanWS,
 		combinator.StrLit("->"),
 		combinator.CanWS,
-		Type,
+		Expr,
+	).MapSpan(func(v interface{}, start, end combinator.Position) interface{} {
+		vs := v.([]interface{})
+		return ast.Case{
+			Pattern: vs[0].(ast.Pattern),
+			Body:    vs[4].(ast.Expr),
+			Span:    span(start, end),
+		}
+	}).Rename("Case")(input)
+}
+
+// Match parses a match expression, whose cases are separated by semicolons,
+// e.g., `match s { Circle r -> r; Rect (w, h) -> w }`. The last case may be
+// followed by a semicolon.
+func Match(input combinator.Input) combinator.Result {
+	semi := combinator.Seq(
+		combinator.CanWS,
+		combinator.Lit(';'),
+		combinator.CanWS,
+	)
+	return combinator.Seq(
+		Keyword("match"),     // 0
+		combinator.CanWS,     // 1
+		Expr,                 // 2
+		combinator.CanWS,     // 3
+		combinator.Lit('{'),  // 4
+		combinator.CanWS,     // 5
+		List(Case, semi),     // 6
+		combinator.Opt(semi), // 7
+		combinator.CanWS,     // 8
+		combinator.Lit('}'),  // 9
 	).MapSlice(func(vs []interface{}) interface{} {
-		return ast.FuncSpec{
-			Arg: ast.TypeRef{Name: string(vs[0].(ast.Ident))},
-			Ret: vs[1].(ast.Type),
+		caseNodes := vs[6].([]interface{})
+		cases := make([]ast.Case, len(caseNodes))
+		for i, v := range caseNodes {
+			cases[i] = v.(ast.Case)
 		}
-	}).Wrap()(input)
+		return ast.Match{Expr: vs[2].(ast.Expr), Cases: cases}
+	}).Rename("Match")(input)
 }
 
+// If parses a conditional expression, e.g., `if a < b then a else b`.
+func If(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		Keyword("if"),    // 0
+		combinator.CanWS, // 1
+		Expr,             // 2
+		combinator.CanWS, // 3
+		Keyword("then"),  // 4
+		combinator.CanWS, // 5
+		Expr,             // 6
+		combinator.CanWS, // 7
+		Keyword("else"),  // 8
+		combinator.CanWS, // 9
+		Expr,             // 10
+	).MapSlice(func(vs []interface{}) interface{} {
+		return ast.If{
+			Cond: vs[2].(ast.Expr),
+			Then: vs[6].(ast.Expr),
+			Else: vs[10].(ast.Expr),
+		}
+	}).Rename("If")(input)
+}
+
+// Call parses the application of a function to one or more arguments, e.g.,
+// `f a b c`. Application is left-associative, so the result is nested calls
+// of one argument each: `((f a) b) c`.
+func Call(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		Atom,
+		combinator.OneOrMore(combinator.Seq(combinator.WS, Atom).Get(1)),
+	).MapSlice(func(vs []interface{}) interface{} {
+		fn := vs[0].(ast.Expr)
+		args := vs[1].([]interface{})
+		for _, v := range args[:len(args)-1] {
+			arg := v.(ast.Expr)
+			fn = ast.Expr{
+				Node: ast.Call{Fn: fn, Arg: arg},
+				Span: ast.Span{Start: fn.Span.Start, End: arg.Span.End},
+			}
+		}
+		return ast.Call{Fn: fn, Arg: args[len(args)-1].(ast.Expr)}
+	}).Rename("Call")(input)
+}
+
+// FuncLit parses a function literal, e.g., `x -> x + 1`. An argument with a
+// type annotation must be parenthesized, e.g., `(x : int) -> x + 1`.
 func FuncLit(input combinator.Input) combinator.Result {
 	return combinator.Seq(
-		Ident,
+		combinator.Any(
+			Ident.Map(func(v interface{}) interface{} {
+				return ast.ArgSpec{Name: string(v.(ast.Ident))}
+			}),
+			combinator.Seq(
+				combinator.Lit('('),
+				combinator.CanWS,
+				ArgSpec,
+				combinator.Lit(')'),
+			).Get(2),
+		),
 		combinator.CanWS,
 		combinator.StrLit("->"),
 		combinator.CanWS,
 		Expr,
 	).MapSlice(func(vs []interface{}) interface{} {
-		return ast.FuncLit{Arg: vs[0].(ast.Ident), Body: vs[4].(ast.Expr)}
-	}).Wrap()(input)
+		arg := vs[0].(ast.ArgSpec)
+		return ast.FuncLit{
+			Arg:     ast.Ident(arg.Name),
+			ArgType: arg.Type,
+			Body:    vs[4].(ast.Expr),
+		}
+	}).Rename("FuncLit")(input)
 }
 
 func LetDecl(input combinator.Input) combinator.Result {
 	return combinator.Seq(
-		combinator.StrLit("let"), // 0
-		combinator.WS,            // 1
-		Ident,                    // 2
-		combinator.CanWS,         // 3
-		combinator.Lit('='),      // 4
-		combinator.CanWS,         // 5
-		Expr,                     // 6
-	).MapSlice(func(vs []interface{}) interface{} {
-		return ast.LetDecl{vs[2].(ast.Ident), vs[6].(ast.Expr)}
-	}).Wrap()(input)
+		combinator.StrLit("let"),   // 0
+		combinator.WS,              // 1
+		Ident,                      // 2
+		combinator.CanWS,           // 3
+		combinator.Opt(annotation), // 4
+		combinator.Lit('='),        // 5
+		combinator.CanWS,           // 6
+		Expr,                       // 7
+	).MapSpan(func(v interface{}, start, end combinator.Position) interface{} {
+		vs := v.([]interface{})
+		var typ ast.Type
+		if vs[4] != nil {
+			typ = vs[4].(ast.Type)
+		}
+		return ast.LetDecl{
+			Ident:   vs[2].(ast.Ident),
+			Type:    typ,
+			Binding: vs[7].(ast.Expr),
+			Span:    span(start, end),
+		}
+	}).Rename("LetDecl")(input)
 }
 
 func Decl(input combinator.Input) combinator.Result {
-	return combinator.Any(LetDecl, TypeDecl).Wrap()(input)
+	return combinator.Any(LetDecl, TypeDecl).Rename("Decl")(input)
 }
 
 func Stmt(input combinator.Input) combinator.Result {
 	return combinator.Seq(
 		combinator.Any(Decl, Expr),
 		combinator.EOS,
-	).Get(0).Wrap()(input)
+	).Get(0).Rename("Stmt")(input)
 }
 
-var (
-	TypeLit = combinator.Any(
-		combinator.Ident.Map(func(v interface{}) interface{} {
-			return ast.TypeRef{Name: v.(string)}
-		}),
-		TupleSpec,
-		FuncSpec,
-	).Rename("TypeLit")
+// DocStmt parses a statement along with the whitespace which precedes it. If
+// the statement is a declaration, the `///` doc comment immediately preceding
+// it (if any) becomes the declaration's Doc.
+func DocStmt(input combinator.Input) combinator.Result {
+	return combinator.Seq(
+		combinator.CanWS,
+		Stmt,
+	).MapSlice(func(vs []interface{}) interface{} {
+		doc := docComment(vs[0].(string))
+		switch decl := vs[1].(type) {
+		case ast.LetDecl:
+			decl.Doc = doc
+			return decl
+		case ast.TypeDecl:
+			decl.Doc = doc
+			return decl
+		default:
+			return decl
+		}
+	}).Rename("DocStmt")(input)
+}
+
+// docComment returns the text of the `///` comment lines at the end of `ws`,
+// the whitespace preceding a declaration. The slashes and a single following
+// space are removed from each line.
+func docComment(ws string) string {
+	lines := strings.Split(ws, "\n")
+	// the last line is the indentation of the declaration itself
+	lines = lines[:len(lines)-1]
 
+	var doc []string
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "///") || strings.HasPrefix(line, "////") {
+			break
+		}
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "///"), " ")
+		doc = append([]string{line}, doc...)
+	}
+	return strings.Join(doc, "\n")
+}
+
+// Keywords are the words which can't be used as identifiers.
+var Keywords = map[string]bool{
+	"let":   true,
+	"type":  true,
+	"if":    true,
+	"then":  true,
+	"else":  true,
+	"match": true,
+}
+
+// Keyword returns a parser which matches the keyword `s` as long as it isn't
+// the prefix of a longer identifier (e.g., "if" doesn't match "iffy").
+func Keyword(s string) combinator.Parser {
+	return combinator.Parser(func(
+		input combinator.Input,
+	) combinator.Result {
+		r := combinator.Ident(input)
+		if r.Err != nil || r.Value.(string) != s {
+			return combinator.ERR(
+				combinator.Failure{
+					Pos:      input.Pos(),
+					Expected: []string{strconv.Quote(s)},
+				},
+				input,
+			)
+		}
+		return r
+	}).Rename("Keyword")
+}
+
+var (
 	TypeDecl = combinator.Seq(
 		combinator.StrLit("type"), // 0
 		combinator.WS,             // 1
 		combinator.Seq(
-			combinator.Ident,
+			Ident,
 			combinator.Repeat(
 				combinator.Seq(combinator.WS, combinator.Ident).Get(1),
 			),
 		), // 2
-		combinator.CanWS,    // 3
-		combinator.Lit('='), // 4
-		combinator.CanWS,    // 5
-		Type,                // 6
-	).MapSlice(func(vs []interface{}) interface{} {
+		combinator.CanWS,              // 3
+		combinator.Lit('='),           // 4
+		combinator.CanWS,              // 5
+		combinator.Any(SumSpec, Type), // 6
+	).MapSpan(func(v interface{}, start, end combinator.Position) interface{} {
+		vs := v.([]interface{})
 		typeExpr := vs[2].([]interface{})
 
 		argNodes := typeExpr[1].([]interface{})
@@ -334,9 +864,10 @@ var (
 		}
 
 		return ast.TypeDecl{
-			Name: typeExpr[0].(string),
+			Name: string(typeExpr[0].(ast.Ident)),
 			Type: vs[6].(ast.Type),
 			Args: args,
+			Span: span(start, end),
 		}
 	}).Rename("TypeDecl")
 
@@ -348,28 +879,53 @@ var (
 		return ast.StringLit(v.(string))
 	}).Rename("StringLit")
 
-	// FuncLit = Seq(FuncSpec, WS, Expr)
-
-	Ident = combinator.Ident.Map(func(v interface{}) interface{} {
-		return ast.Ident(v.(string))
+	// Ident matches identifiers which aren't keywords. Identifiers (other
+	// than `_`) can't start with an underscore, since such names are
+	// reserved for the variables and type parameters of generated code.
+	Ident = combinator.Parser(func(
+		input combinator.Input,
+	) combinator.Result {
+		r := combinator.Ident(input)
+		if r.Err != nil {
+			return r
+		}
+		s := r.Value.(string)
+		if Keywords[s] || strings.HasPrefix(s, "_") && s != "_" {
+			expected := "identifier"
+			if !Keywords[s] {
+				expected = "identifier which doesn't start with '_'"
+			}
+			return combinator.ERR(
+				combinator.Failure{
+					Pos:      input.Pos(),
+					Expected: []string{expected},
+				},
+				input,
+			)
+		}
+		return r.Map(func(v interface{}) interface{} {
+			return ast.Ident(v.(string))
+		})
 	}).Rename("Ident")
 
 	File = combinator.Seq(
 		combinator.StrLit("package"), // 0
 		combinator.WS,                // 1
 		combinator.Ident,             // 2
-		combinator.Repeat(combinator.Seq(
-			combinator.CanWS,
-			Stmt,
-		).Get(1)), // 3
-		combinator.CanWS, // 4
-		combinator.EOF,   // 5
-	).MapSlice(func(vs []interface{}) interface{} {
+		combinator.Repeat(DocStmt),   // 3
+		combinator.CanWS,             // 4
+		combinator.EOF,               // 5
+	).MapSpan(func(v interface{}, start, end combinator.Position) interface{} {
+		vs := v.([]interface{})
 		stmtNodes := vs[3].([]interface{})
 		stmts := make([]ast.Stmt, len(stmtNodes))
 		for i, v := range stmtNodes {
 			stmts[i] = v.(ast.Stmt)
 		}
-		return ast.File{Package: vs[2].(string), Stmts: stmts}
+		return ast.File{
+			Package: vs[2].(string),
+			Stmts:   stmts,
+			Span:    span(start, end),
+		}
 	}).Rename("File")
 )
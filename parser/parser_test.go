@@ -13,8 +13,8 @@ import (
 func TestParser(t *testing.T) {
 	testCases := []struct {
 		Name        string
-		Input       combinator.Input
-		WantedRest  combinator.Input
+		Input       string
+		WantedRest  string
 		WantedValue interface{}
 		WantedErr   bool
 		Parser      combinator.Parser
@@ -31,56 +31,36 @@ func TestParser(t *testing.T) {
 			WantedValue: " \t\n",
 			Parser:      combinator.CanWS,
 		},
-		// {
-		// 	Name:        "func-spec-simple",
-		// 	Input:       "fn()",
-		// 	WantedValue: ast.FuncSpec{},
-		// 	Parser:      FuncSpec,
-		// },
-		// {
-		// 	Name:        "func-spec-no-args-w-ret",
-		// 	Input:       "fn() -> int",
-		// 	WantedValue: ast.FuncSpec{Ret: ast.TypeRef{Name: "int"}},
-		// 	Parser:      FuncSpec,
-		// },
-		// {
-		// 	Name:  "func-spec-one-arg-no-ret",
-		// 	Input: "fn(i int)",
-		// 	WantedValue: ast.FuncSpec{
-		// 		Arg: ast.ArgSpec{"i", ast.TypeRef{Name: "int"}},
-		// 	},
-		// 	Parser: FuncSpec,
-		// },
-		// {
-		// 	Name:  "func-spec-multi-args-no-ret",
-		// 	Input: "fn(i int, j int)",
-		// 	WantedValue: ast.FuncSpec{Args: []ast.ArgSpec{
-		// 		{"i", ast.TypeRef{Name: "int"}},
-		// 		{"j", ast.TypeRef{Name: "int"}},
-		// 	}},
-		// 	Parser: FuncSpec,
-		// },
-		// {
-		// 	Name:  "func-spec-multi-args-w-ret",
-		// 	Input: "fn(i int, j int) -> bool",
-		// 	WantedValue: ast.FuncSpec{
-		// 		Args: []ast.ArgSpec{
-		// 			{"i", ast.TypeRef{Name: "int"}},
-		// 			{"j", ast.TypeRef{Name: "int"}},
-		// 		},
-		// 		Ret: ast.TypeRef{Name: "bool"},
-		// 	},
-		// 	Parser: FuncSpec,
-		// },
-		// {
-		// 	Name:  "func-spec-w-untyped-args",
-		// 	Input: "fn(i, j)",
-		// 	WantedValue: ast.FuncSpec{Args: []ast.ArgSpec{
-		// 		{Name: "i"},
-		// 		{Name: "j"},
-		// 	}},
-		// 	Parser: FuncSpec,
-		// },
+		{
+			Name:        "ws-comments",
+			Input:       " // line\n/* a /* nested */ b */\t;",
+			WantedValue: " // line\n/* a /* nested */ b */\t",
+			WantedRest:  ";",
+			Parser:      combinator.WS,
+		},
+		{
+			Name:        "can-ws-line-comment-at-eof",
+			Input:       "// the end",
+			WantedValue: "// the end",
+			Parser:      combinator.CanWS,
+		},
+		{
+			Name:       "ws-slash-is-not-a-comment",
+			Input:      "/ 2",
+			WantedErr:  true,
+			WantedRest: "/ 2",
+			Parser:     combinator.WS,
+		},
+		{
+			Name:  "expr-binary-comments",
+			Input: "a /* divided */ / // by\n b",
+			WantedValue: binary(
+				"/",
+				ast.Expr{Node: ast.Ident("a")},
+				ast.Expr{Node: ast.Ident("b")},
+			),
+			Parser: Expr,
+		},
 		{
 			Name:  "type-decl-simple",
 			Input: "type foo = int",
@@ -90,6 +70,13 @@ func TestParser(t *testing.T) {
 			},
 			Parser: TypeDecl,
 		},
+		{
+			Name:       "type-decl-leading-underscore",
+			Input:      "type _foo = int",
+			WantedRest: "type _foo = int",
+			WantedErr:  true,
+			Parser:     TypeDecl,
+		},
 		{
 			Name:  "type-decl-generic",
 			Input: "type foo a b = bar a b",
@@ -97,7 +84,10 @@ func TestParser(t *testing.T) {
 				Name: "foo",
 				Type: ast.TypeRef{
 					Name: "bar",
-					Arg:  ast.TypeRef{Name: "a", Arg: ast.TypeRef{Name: "b"}},
+					Args: []ast.Type{
+						ast.TypeRef{Name: "a"},
+						ast.TypeRef{Name: "b"},
+					},
 				},
 				Args: []ast.TypeVar{"a", "b"},
 			},
@@ -117,20 +107,47 @@ func TestParser(t *testing.T) {
 			},
 			Parser: TypeDecl,
 		},
-		// {
-		// 	Name:  "type-decl-simple-function",
-		// 	Input: "type Parser = fn(input Input) -> Result",
-		// 	WantedValue: ast.TypeDecl{
-		// 		Name: "Parser",
-		// 		Type: ast.FuncSpec{
-		// 			Args: []ast.ArgSpec{
-		// 				{Name: "input", Type: ast.TypeRef{Name: "Input"}},
-		// 			},
-		// 			Ret: ast.TypeRef{Name: "Result"},
-		// 		},
-		// 	},
-		// 	Parser: TypeDecl,
-		// },
+		{
+			Name:  "type-decl-sum",
+			Input: "type Shape = Circle int | Rect (int, int) | Point",
+			WantedValue: ast.TypeDecl{
+				Name: "Shape",
+				Type: ast.SumSpec{
+					{Name: "Circle", Type: ast.TypeRef{Name: "int"}},
+					{
+						Name: "Rect",
+						Type: ast.TupleSpec{
+							ast.TypeRef{Name: "int"},
+							ast.TypeRef{Name: "int"},
+						},
+					},
+					{Name: "Point"},
+				},
+			},
+			Parser: TypeDecl,
+		},
+		{
+			Name:  "type-decl-sum-generic",
+			Input: "type Option a = None | Some a",
+			WantedValue: ast.TypeDecl{
+				Name: "Option",
+				Type: ast.SumSpec{
+					{Name: "None"},
+					{Name: "Some", Type: ast.TypeRef{Name: "a"}},
+				},
+				Args: []ast.TypeVar{"a"},
+			},
+			Parser: TypeDecl,
+		},
+		{
+			Name:  "type-decl-sum-leading-bar",
+			Input: "type Unit =\n    | Unit",
+			WantedValue: ast.TypeDecl{
+				Name: "Unit",
+				Type: ast.SumSpec{{Name: "Unit"}},
+			},
+			Parser: TypeDecl,
+		},
 		{
 			Name:        "string-lit-empty",
 			Input:       `""`,
@@ -163,10 +180,17 @@ func TestParser(t *testing.T) {
 		},
 		{
 			Name:        "ident-many-chars",
-			Input:       "_foo123",
-			WantedValue: ast.Ident("_foo123"),
+			Input:       "foo_123",
+			WantedValue: ast.Ident("foo_123"),
 			Parser:      Ident,
 		},
+		{
+			Name:       "ident-leading-underscore",
+			Input:      "_r",
+			WantedRest: "_r",
+			WantedErr:  true,
+			Parser:     Ident,
+		},
 		{
 			Name:        "tuple-lit-empty",
 			Input:       "()",
@@ -177,8 +201,24 @@ func TestParser(t *testing.T) {
 			Name:  "let-decl-no-type",
 			Input: "let x = 42",
 			WantedValue: ast.LetDecl{
-				ast.Ident("x"),
-				ast.Expr{Node: ast.IntLit(42)},
+				Ident:   ast.Ident("x"),
+				Binding: ast.Expr{Node: ast.IntLit(42)},
+			},
+			Parser: LetDecl,
+		},
+		{
+			Name:  "let-decl-w-type",
+			Input: "let f : int -> int = x -> x",
+			WantedValue: ast.LetDecl{
+				Ident: ast.Ident("f"),
+				Type: ast.FuncSpec{
+					Arg: ast.TypeRef{Name: "int"},
+					Ret: ast.TypeRef{Name: "int"},
+				},
+				Binding: ast.Expr{Node: ast.FuncLit{
+					Arg:  "x",
+					Body: ast.Expr{Node: ast.Ident("x")},
+				}},
 			},
 			Parser: LetDecl,
 		},
@@ -204,8 +244,8 @@ func TestParser(t *testing.T) {
 			Name:  "block-w-let-stmt",
 			Input: "{ let x = 42; }",
 			WantedValue: ast.Block{Stmts: []ast.Stmt{ast.LetDecl{
-				ast.Ident("x"),
-				ast.Expr{Node: ast.IntLit(42)},
+				Ident:   ast.Ident("x"),
+				Binding: ast.Expr{Node: ast.IntLit(42)},
 			}}},
 			Parser: Block,
 		},
@@ -242,6 +282,64 @@ func TestParser(t *testing.T) {
 			},
 			Parser: Call,
 		},
+		{
+			Name:  "call-many-args",
+			Input: "f a b c;",
+			WantedValue: ast.Call{
+				Fn: ast.Expr{Node: ast.Call{
+					Fn: ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("f")},
+						Arg: ast.Expr{Node: ast.Ident("a")},
+					}},
+					Arg: ast.Expr{Node: ast.Ident("b")},
+				}},
+				Arg: ast.Expr{Node: ast.Ident("c")},
+			},
+			WantedRest: ";",
+			Parser:     Call,
+		},
+		{
+			Name:  "call-args-parens-and-tuples",
+			Input: "f (g x y) (1, \"a\") z",
+			WantedValue: ast.Call{
+				Fn: ast.Expr{Node: ast.Call{
+					Fn: ast.Expr{Node: ast.Call{
+						Fn: ast.Expr{Node: ast.Ident("f")},
+						Arg: ast.Expr{Node: ast.Call{
+							Fn: ast.Expr{Node: ast.Call{
+								Fn:  ast.Expr{Node: ast.Ident("g")},
+								Arg: ast.Expr{Node: ast.Ident("x")},
+							}},
+							Arg: ast.Expr{Node: ast.Ident("y")},
+						}},
+					}},
+					Arg: ast.Expr{Node: ast.TupleLit{
+						{Node: ast.IntLit(1)},
+						{Node: ast.StringLit("a")},
+					}},
+				}},
+				Arg: ast.Expr{Node: ast.Ident("z")},
+			},
+			Parser: Call,
+		},
+		{
+			Name:  "call-parenthesized-fn",
+			Input: "(f a b)\n    c d",
+			WantedValue: ast.Call{
+				Fn: ast.Expr{Node: ast.Call{
+					Fn: ast.Expr{Node: ast.Call{
+						Fn: ast.Expr{Node: ast.Call{
+							Fn:  ast.Expr{Node: ast.Ident("f")},
+							Arg: ast.Expr{Node: ast.Ident("a")},
+						}},
+						Arg: ast.Expr{Node: ast.Ident("b")},
+					}},
+					Arg: ast.Expr{Node: ast.Ident("c")},
+				}},
+				Arg: ast.Expr{Node: ast.Ident("d")},
+			},
+			Parser: Call,
+		},
 		{
 			Name:  "func-lit-int-body",
 			Input: "_ -> 4",
@@ -346,14 +444,36 @@ func TestParser(t *testing.T) {
 			Name:  "expr-func-lit",
 			Input: "a -> addOne a",
 			WantedValue: ast.Expr{Node: ast.FuncLit{
-				ast.Ident("a"),
-				ast.Expr{Node: ast.Call{
+				Arg: ast.Ident("a"),
+				Body: ast.Expr{Node: ast.Call{
 					ast.Expr{Node: ast.Ident("addOne")},
 					ast.Expr{Node: ast.Ident("a")},
 				}},
 			}},
 			Parser: Expr,
 		},
+		{
+			Name:  "expr-func-lit-annotated",
+			Input: "( a : Option int ) -> a",
+			WantedValue: ast.Expr{Node: ast.FuncLit{
+				Arg: ast.Ident("a"),
+				ArgType: ast.TypeRef{
+					Name: "Option",
+					Args: []ast.Type{ast.TypeRef{Name: "int"}},
+				},
+				Body: ast.Expr{Node: ast.Ident("a")},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-func-lit-parenthesized-arg",
+			Input: "(a) -> a",
+			WantedValue: ast.Expr{Node: ast.FuncLit{
+				Arg:  ast.Ident("a"),
+				Body: ast.Expr{Node: ast.Ident("a")},
+			}},
+			Parser: Expr,
+		},
 		{
 			Name:  "expr-parens-group",
 			Input: "print (add 1 2)",
@@ -369,6 +489,308 @@ func TestParser(t *testing.T) {
 			}},
 			Parser: Expr,
 		},
+		{
+			Name:  "expr-binary-precedence",
+			Input: "1 + 2 * 3",
+			WantedValue: binary(
+				"+",
+				ast.Expr{Node: ast.IntLit(1)},
+				binary(
+					"*",
+					ast.Expr{Node: ast.IntLit(2)},
+					ast.Expr{Node: ast.IntLit(3)},
+				),
+			),
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-binary-left-assoc",
+			Input: "a-b - c",
+			WantedValue: binary(
+				"-",
+				binary(
+					"-",
+					ast.Expr{Node: ast.Ident("a")},
+					ast.Expr{Node: ast.Ident("b")},
+				),
+				ast.Expr{Node: ast.Ident("c")},
+			),
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-binary-call-operands",
+			Input: "f x <= g y || ok",
+			WantedValue: binary(
+				"||",
+				binary(
+					"<=",
+					ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("f")},
+						Arg: ast.Expr{Node: ast.Ident("x")},
+					}},
+					ast.Expr{Node: ast.Call{
+						Fn:  ast.Expr{Node: ast.Ident("g")},
+						Arg: ast.Expr{Node: ast.Ident("y")},
+					}},
+				),
+				ast.Expr{Node: ast.Ident("ok")},
+			),
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-binary-func-lit-body",
+			Input: "x -> x - 1",
+			WantedValue: ast.Expr{Node: ast.FuncLit{
+				Arg: "x",
+				Body: binary(
+					"-",
+					ast.Expr{Node: ast.Ident("x")},
+					ast.Expr{Node: ast.IntLit(1)},
+				),
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-binary-non-assoc",
+			Input: "a == b == c",
+			WantedValue: binary(
+				"==",
+				ast.Expr{Node: ast.Ident("a")},
+				ast.Expr{Node: ast.Ident("b")},
+			),
+			WantedRest: " == c",
+			Parser:     Expr,
+		},
+		{
+			Name:  "expr-if",
+			Input: "if a < b then a else f b",
+			WantedValue: ast.Expr{Node: ast.If{
+				Cond: binary(
+					"<",
+					ast.Expr{Node: ast.Ident("a")},
+					ast.Expr{Node: ast.Ident("b")},
+				),
+				Then: ast.Expr{Node: ast.Ident("a")},
+				Else: ast.Expr{Node: ast.Call{
+					Fn:  ast.Expr{Node: ast.Ident("f")},
+					Arg: ast.Expr{Node: ast.Ident("b")},
+				}},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-if-else-if",
+			Input: "if(a)then 1 else if b then 2 else 3",
+			WantedValue: ast.Expr{Node: ast.If{
+				Cond: ast.Expr{Node: ast.Ident("a")},
+				Then: ast.Expr{Node: ast.IntLit(1)},
+				Else: ast.Expr{Node: ast.If{
+					Cond: ast.Expr{Node: ast.Ident("b")},
+					Then: ast.Expr{Node: ast.IntLit(2)},
+					Else: ast.Expr{Node: ast.IntLit(3)},
+				}},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name: "expr-match",
+			Input: "match s {\n" +
+				"    Circle r -> r;\n" +
+				"    Rect (w, _) -> w;\n" +
+				"    Some (Some 1) -> 1;\n" +
+				"    (\"a\", ()) -> 2;\n" +
+				"    x -> 0;\n" +
+				"}",
+			WantedValue: ast.Expr{Node: ast.Match{
+				Expr: ast.Expr{Node: ast.Ident("s")},
+				Cases: []ast.Case{
+					{
+						Pattern: ctorPattern("Circle", varPattern("r")),
+						Body:    ast.Expr{Node: ast.Ident("r")},
+					},
+					{
+						Pattern: ctorPattern("Rect", ast.Pattern{
+							Node: ast.TuplePattern{
+								varPattern("w"),
+								{Node: ast.WildcardPattern{}},
+							},
+						}),
+						Body: ast.Expr{Node: ast.Ident("w")},
+					},
+					{
+						Pattern: ctorPattern("Some", ctorPattern(
+							"Some",
+							ast.Pattern{
+								Node: ast.LitPattern{Lit: ast.IntLit(1)},
+							},
+						)),
+						Body: ast.Expr{Node: ast.IntLit(1)},
+					},
+					{
+						Pattern: ast.Pattern{Node: ast.TuplePattern{
+							{Node: ast.LitPattern{Lit: ast.StringLit("a")}},
+							{Node: ast.TuplePattern{}},
+						}},
+						Body: ast.Expr{Node: ast.IntLit(2)},
+					},
+					{
+						Pattern: varPattern("x"),
+						Body:    ast.Expr{Node: ast.IntLit(0)},
+					},
+				},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-match-single-case",
+			Input: "match (a, b) { (x, y) -> add x y }",
+			WantedValue: ast.Expr{Node: ast.Match{
+				Expr: ast.Expr{Node: ast.TupleLit{
+					{Node: ast.Ident("a")},
+					{Node: ast.Ident("b")},
+				}},
+				Cases: []ast.Case{{
+					Pattern: ast.Pattern{Node: ast.TuplePattern{
+						varPattern("x"),
+						varPattern("y"),
+					}},
+					Body: ast.Expr{Node: ast.Call{
+						Fn: ast.Expr{Node: ast.Call{
+							Fn:  ast.Expr{Node: ast.Ident("add")},
+							Arg: ast.Expr{Node: ast.Ident("x")},
+						}},
+						Arg: ast.Expr{Node: ast.Ident("y")},
+					}},
+				}},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:       "expr-match-no-cases",
+			Input:      "match x {}",
+			WantedErr:  true,
+			WantedRest: "match x {}",
+			Parser:     Expr,
+		},
+		{
+			Name:  "type-record",
+			Input: "{name: string, age: Option int,}",
+			WantedValue: ast.RecordSpec{Fields: []ast.Field{
+				{Name: "age", Type: ast.TypeRef{
+					Name: "Option",
+					Args: []ast.Type{ast.TypeRef{Name: "int"}},
+				}},
+				{Name: "name", Type: ast.TypeRef{Name: "string"}},
+			}},
+			Parser: Type,
+		},
+		{
+			Name:        "type-record-empty",
+			Input:       "{ }",
+			WantedValue: ast.RecordSpec{Fields: []ast.Field{}},
+			Parser:      Type,
+		},
+		{
+			Name:  "type-record-open",
+			Input: "{name: string, | r }",
+			WantedValue: ast.RecordSpec{
+				Fields: []ast.Field{
+					{Name: "name", Type: ast.TypeRef{Name: "string"}},
+				},
+				Rest: "r",
+			},
+			Parser: Type,
+		},
+		{
+			Name:        "type-record-open-empty",
+			Input:       "{|r}",
+			WantedValue: ast.RecordSpec{Fields: []ast.Field{}, Rest: "r"},
+			Parser:      Type,
+		},
+		{
+			Name:  "expr-record-lit",
+			Input: "{name = \"Alice\", age = 1 + 2}",
+			WantedValue: ast.Expr{Node: ast.RecordLit{
+				{Name: "name", Value: ast.Expr{Node: ast.StringLit("Alice")}},
+				{Name: "age", Value: binary(
+					"+",
+					ast.Expr{Node: ast.IntLit(1)},
+					ast.Expr{Node: ast.IntLit(2)},
+				)},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-record-update",
+			Input: "{ p | age = 3, }",
+			WantedValue: ast.Expr{Node: ast.RecordUpdate{
+				Record: ast.Expr{Node: ast.Ident("p")},
+				Fields: []ast.FieldValue{
+					{Name: "age", Value: ast.Expr{Node: ast.IntLit(3)}},
+				},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-block-not-record",
+			Input: "{ a == b }",
+			WantedValue: ast.Expr{Node: ast.Block{
+				Expr: binary(
+					"==",
+					ast.Expr{Node: ast.Ident("a")},
+					ast.Expr{Node: ast.Ident("b")},
+				),
+			}},
+			Parser: Expr,
+		},
+		{
+			// projection binds more tightly than application
+			Name:  "expr-project",
+			Input: "f p.address.city",
+			WantedValue: ast.Expr{Node: ast.Call{
+				Fn: ast.Expr{Node: ast.Ident("f")},
+				Arg: ast.Expr{Node: ast.Project{
+					Record: ast.Expr{Node: ast.Project{
+						Record: ast.Expr{Node: ast.Ident("p")},
+						Field:  "address",
+					}},
+					Field: "city",
+				}},
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:  "expr-project-parens",
+			Input: "(f p).name",
+			WantedValue: ast.Expr{Node: ast.Project{
+				Record: ast.Expr{Node: ast.Call{
+					Fn:  ast.Expr{Node: ast.Ident("f")},
+					Arg: ast.Expr{Node: ast.Ident("p")},
+				}},
+				Field: "name",
+			}},
+			Parser: Expr,
+		},
+		{
+			Name:        "ident-keyword-prefix",
+			Input:       "iffy",
+			WantedValue: ast.Ident("iffy"),
+			Parser:      Ident,
+		},
+		{
+			Name:       "ident-keyword",
+			Input:      "then",
+			WantedErr:  true,
+			WantedRest: "then",
+			Parser:     Ident,
+		},
+		{
+			Name:        "expr-binary-missing-operand",
+			Input:       "a + ;",
+			WantedValue: ast.Expr{Node: ast.Ident("a")},
+			WantedRest:  " + ;",
+			Parser:      Expr,
+		},
 		{
 			Name:  "decl-type-decl",
 			Input: "type foo = int",
@@ -382,8 +804,8 @@ func TestParser(t *testing.T) {
 			Name:  "decl-let-decl",
 			Input: "let x = 0",
 			WantedValue: ast.LetDecl{
-				ast.Ident("x"),
-				ast.Expr{Node: ast.IntLit(0)},
+				Ident:   ast.Ident("x"),
+				Binding: ast.Expr{Node: ast.IntLit(0)},
 			},
 			Parser: Decl,
 		},
@@ -391,8 +813,8 @@ func TestParser(t *testing.T) {
 			Name:  "stmt-decl",
 			Input: "let x = 0;",
 			WantedValue: ast.LetDecl{
-				ast.Ident("x"),
-				ast.Expr{Node: ast.IntLit(0)},
+				Ident:   ast.Ident("x"),
+				Binding: ast.Expr{Node: ast.IntLit(0)},
 			},
 			Parser: Stmt,
 		},
@@ -460,8 +882,8 @@ func TestParser(t *testing.T) {
 				Stmts: []ast.Stmt{
 					ast.TypeDecl{Name: "X", Type: ast.TypeRef{Name: "Foo"}},
 					ast.LetDecl{
-						ast.Ident("main"),
-						ast.Expr{Node: ast.FuncLit{
+						Ident: ast.Ident("main"),
+						Binding: ast.Expr{Node: ast.FuncLit{
 							Arg: "_",
 							Body: ast.Expr{Node: ast.Block{
 								Stmts: []ast.Stmt{ast.Expr{Node: ast.Call{
@@ -480,7 +902,7 @@ func TestParser(t *testing.T) {
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.Name, func(t *testing.T) {
-			result := testCase.Parser(testCase.Input)
+			result := testCase.Parser(combinator.NewInput(testCase.Input))
 			if testCase.WantedErr && result.Err == nil {
 				t.Fatal("Wanted an error but didn't get any")
 			}
@@ -488,11 +910,11 @@ func TestParser(t *testing.T) {
 				t.Fatal("Unexpected error:", result)
 			}
 
-			if testCase.WantedRest != result.Rest {
+			if testCase.WantedRest != result.Rest.String() {
 				t.Fatalf(
 					"Wanted REST: %#v; got REST: %#v",
 					testCase.WantedRest,
-					result.Rest,
+					result.Rest.String(),
 				)
 			}
 
@@ -502,6 +924,13 @@ func TestParser(t *testing.T) {
 						return
 					}
 				}
+			} else if wanted, ok := testCase.WantedValue.(ast.ExprNode); ok {
+				// compare with EqualExprNode so spans are ignored
+				if got, ok := result.Value.(ast.ExprNode); ok {
+					if wanted.EqualExprNode(got) {
+						return
+					}
+				}
 			} else {
 				if reflect.DeepEqual(result.Value, testCase.WantedValue) {
 					return
@@ -516,3 +945,393 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+// binary returns the desugared form of the infix expression `l op r`.
+func binary(op string, l, r ast.Expr) ast.Expr {
+	return ast.Expr{Node: ast.Call{
+		Fn: ast.Expr{Node: ast.Call{
+			Fn:  ast.Expr{Node: ast.Ident(op)},
+			Arg: l,
+		}},
+		Arg: r,
+	}}
+}
+
+func varPattern(name string) ast.Pattern {
+	return ast.Pattern{Node: ast.VarPattern(name)}
+}
+
+func ctorPattern(name string, arg ast.Pattern) ast.Pattern {
+	return ast.Pattern{Node: ast.CtorPattern{
+		Ctor: ast.Expr{Node: ast.Ident(name)},
+		Arg:  arg,
+	}}
+}
+
+func TestSpans(t *testing.T) {
+	input := "package main\n\nlet x = add 1\n    (f y);\n"
+	result := File(combinator.NewInput(input))
+	if result.Err != nil {
+		t.Fatal("Unexpected error:", result)
+	}
+	file := result.Value.(ast.File)
+	letDecl := file.Stmts[0].(ast.LetDecl)
+	call := letDecl.Binding.Node.(ast.Call)
+	inner := call.Fn.Node.(ast.Call)
+
+	testCases := []struct {
+		Name   string
+		Span   ast.Span
+		Wanted string
+	}{
+		{Name: "file", Span: file.Span, Wanted: input},
+		{
+			Name:   "let-decl",
+			Span:   letDecl.Span,
+			Wanted: "let x = add 1\n    (f y)",
+		},
+		{
+			Name:   "call",
+			Span:   letDecl.Binding.Span,
+			Wanted: "add 1\n    (f y)",
+		},
+		{Name: "inner-call", Span: call.Fn.Span, Wanted: "add 1"},
+		{Name: "ident", Span: inner.Fn.Span, Wanted: "add"},
+		{Name: "int-lit", Span: inner.Arg.Span, Wanted: "1"},
+		{Name: "paren-group", Span: call.Arg.Span, Wanted: "f y"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			got := input[testCase.Span.Start.Offset:testCase.Span.End.Offset]
+			if got != testCase.Wanted {
+				t.Fatalf("Wanted %#v; got %#v", testCase.Wanted, got)
+			}
+		})
+	}
+
+	wanted := ast.Position{Offset: 33, Line: 4, Column: 6}
+	if got := call.Arg.Span.Start; got != wanted {
+		t.Fatalf("Wanted position %#v; got %#v", wanted, got)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `package main
+
+// not a doc comment
+let a = 1;
+
+/// x is documented.
+///
+/// It's also important.
+let x = {
+    /// y is documented too.
+    let y = 2; // trailing comment
+    y
+};
+
+/// Doc comments must be attached to their decl.
+
+let z = /// not a doc comment either
+    3;
+/// t is an int.
+type t = int;
+`
+	result := File(combinator.NewInput(input))
+	if result.Err != nil {
+		t.Fatal("Unexpected error:", result)
+	}
+	stmts := result.Value.(ast.File).Stmts
+	block := stmts[1].(ast.LetDecl).Binding.Node.(ast.Block)
+
+	testCases := []struct {
+		Name   string
+		Got    string
+		Wanted string
+	}{
+		{Name: "no-doc", Got: stmts[0].(ast.LetDecl).Doc},
+		{
+			Name:   "multi-line",
+			Got:    stmts[1].(ast.LetDecl).Doc,
+			Wanted: "x is documented.\n\nIt's also important.",
+		},
+		{
+			Name:   "in-block",
+			Got:    block.Stmts[0].(ast.LetDecl).Doc,
+			Wanted: "y is documented too.",
+		},
+		{Name: "detached", Got: stmts[2].(ast.LetDecl).Doc},
+		{
+			Name:   "type-decl",
+			Got:    stmts[3].(ast.TypeDecl).Doc,
+			Wanted: "t is an int.",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if testCase.Got != testCase.Wanted {
+				t.Fatalf("Wanted %#v; got %#v", testCase.Wanted, testCase.Got)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Input  string
+		Wanted string
+	}{
+		{
+			Name:   "tuple-close",
+			Input:  "package main\n\nlet x = (1, 2;\n",
+			Wanted: "at 3:14 expected ',' or ')'",
+		},
+		{
+			Name:   "tuple-elem",
+			Input:  "package main\n\nlet x = (1, ;\n",
+			Wanted: "at 3:13 expected expression",
+		},
+		{
+			Name:   "nested-paren",
+			Input:  "package main\n\nlet x = f (g 1;\n",
+			Wanted: "at 3:15 expected ')' or ','",
+		},
+		{
+			Name:   "func-body",
+			Input:  "package main\n\nlet f = x -> ;\n",
+			Wanted: "at 3:14 expected expression",
+		},
+		{
+			Name:   "operator-operand",
+			Input:  "package main\n\nlet x = 1 + ;\n",
+			Wanted: "at 3:13 expected expression",
+		},
+		{
+			Name:   "if-missing-else",
+			Input:  "package main\n\nlet x = if a then b;\n",
+			Wanted: "at 3:20 expected \"->\" or \"else\"",
+		},
+		{
+			Name:   "match-missing-arrow",
+			Input:  "package main\n\nlet x = match y { Circle r r };\n",
+			Wanted: "at 3:28 expected \"->\"",
+		},
+		{
+			Name:   "match-missing-pattern",
+			Input:  "package main\n\nlet x = match y { -> 1 };\n",
+			Wanted: "at 3:19 expected pattern",
+		},
+		{
+			Name:   "missing-semicolon",
+			Input:  "package main\n\nlet x = 2\n",
+			Wanted: "at 4:1 expected expression or ';'",
+		},
+		{
+			Name:   "unterminated-string",
+			Input:  "package main\n\nlet s = \"abc",
+			Wanted: "at 3:13 expected '\"'",
+		},
+		{
+			Name:   "block-close",
+			Input:  "package main\n\nlet x = { let y = 1; y ;\n",
+			Wanted: "at 4:1 expected \"let\", \"type\", expression or '}'",
+		},
+		{
+			Name:   "unterminated-block-comment",
+			Input:  "package main\n\nlet x = 1; /* a /* b */\n",
+			Wanted: "at 4:1 expected \"*/\"",
+		},
+		{
+			Name:   "package",
+			Input:  "pkg main",
+			Wanted: "at 1:1 expected \"package\"",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			result := File(combinator.NewInput(testCase.Input))
+			if result.Err == nil {
+				t.Fatal("Wanted an error; got none")
+			}
+			if got := result.Error(); got != testCase.Wanted {
+				t.Fatalf("Wanted %#v; got %#v", testCase.Wanted, got)
+			}
+		})
+	}
+}
+
+// TestNestedParens guards against exponential backtracking: without
+// memoization, this takes far longer than any test timeout.
+func TestNestedParens(t *testing.T) {
+	result := File(combinator.NewInput(nestedParens(100)))
+	if result.Err != nil {
+		t.Fatal("Unexpected error:", result)
+	}
+	expr := result.Value.(ast.File).Stmts[0].(ast.LetDecl).Binding
+	for depth := 0; depth < 100; depth++ {
+		call, ok := expr.Node.(ast.Call)
+		if !ok {
+			t.Fatalf("Wanted a call at depth %d; got %v", depth, expr)
+		}
+		expr = call.Arg
+	}
+	if !expr.Equal(ast.Expr{Node: ast.IntLit(1)}) {
+		t.Fatalf("Wanted 1 at the innermost depth; got %v", expr)
+	}
+}
+
+// TestTypes checks that types are parsed as expected and that each type's
+// String() parses back to the same type.
+func TestTypes(t *testing.T) {
+	ref := func(name string, args ...ast.Type) ast.Type {
+		return ast.TypeRef{Name: name, Args: args}
+	}
+	fn := func(arg, ret ast.Type) ast.Type {
+		return ast.FuncSpec{Arg: arg, Ret: ret}
+	}
+	intRef, stringRef := ref("int"), ref("string")
+
+	testCases := []struct {
+		Name   string
+		Input  string
+		Wanted ast.Type
+		// Canonical is the wanted String() of the type, if it differs from
+		// the input
+		Canonical string
+	}{
+		{Name: "name", Input: "int", Wanted: intRef},
+		{Name: "type-var", Input: "'a", Wanted: ast.TypeVar("a")},
+		{Name: "unit", Input: "()", Wanted: ast.TupleSpec{}},
+		{
+			Name:  "tuple",
+			Input: "(int, 'a, Option int)",
+			Wanted: ast.TupleSpec{
+				intRef,
+				ast.TypeVar("a"),
+				ref("Option", intRef),
+			},
+		},
+		{
+			Name:      "parens",
+			Input:     "((( int )))",
+			Wanted:    intRef,
+			Canonical: "int",
+		},
+		{
+			Name:   "application",
+			Input:  "Map string int",
+			Wanted: ref("Map", stringRef, intRef),
+		},
+		{
+			Name:   "application-w-type-vars",
+			Input:  "Map 'k 'v",
+			Wanted: ref("Map", ast.TypeVar("k"), ast.TypeVar("v")),
+		},
+		{
+			Name:  "nested-application",
+			Input: "Map (Option int) (List 'a)",
+			Wanted: ref(
+				"Map",
+				ref("Option", intRef),
+				ref("List", ast.TypeVar("a")),
+			),
+		},
+		{
+			Name:   "application-of-tuple",
+			Input:  "List (int, string)",
+			Wanted: ref("List", ast.TupleSpec{intRef, stringRef}),
+		},
+		{
+			Name:   "function",
+			Input:  "int -> string",
+			Wanted: fn(intRef, stringRef),
+		},
+		{
+			Name:   "function-right-associative",
+			Input:  "int -> int -> int",
+			Wanted: fn(intRef, fn(intRef, intRef)),
+		},
+		{
+			Name:      "function-parenthesized-result",
+			Input:     "int -> (int -> int)",
+			Wanted:    fn(intRef, fn(intRef, intRef)),
+			Canonical: "int -> int -> int",
+		},
+		{
+			Name:  "function-argument",
+			Input: "('a -> 'b) -> List 'a -> List 'b",
+			Wanted: fn(
+				fn(ast.TypeVar("a"), ast.TypeVar("b")),
+				fn(
+					ref("List", ast.TypeVar("a")),
+					ref("List", ast.TypeVar("b")),
+				),
+			),
+		},
+		{
+			Name:   "application-of-function",
+			Input:  "Option (int -> int)",
+			Wanted: ref("Option", fn(intRef, intRef)),
+		},
+		{
+			Name:  "tuple-of-functions",
+			Input: "(int -> int, () -> ())",
+			Wanted: ast.TupleSpec{
+				fn(intRef, intRef),
+				fn(ast.TupleSpec{}, ast.TupleSpec{}),
+			},
+		},
+		{
+			Name:  "record",
+			Input: "{f: int -> int, xs: List 'a | r}",
+			Wanted: ast.RecordSpec{
+				Fields: []ast.Field{
+					{Name: "f", Type: fn(intRef, intRef)},
+					{Name: "xs", Type: ref("List", ast.TypeVar("a"))},
+				},
+				Rest: "r",
+			},
+			Canonical: "{f: int -> int, xs: List 'a | 'r}",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			result := Type(combinator.NewInput(testCase.Input))
+			if result.Err != nil {
+				t.Fatal("Unexpected error:", result)
+			}
+			if rest := result.Rest.String(); rest != "" {
+				t.Fatalf("Wanted no REST; got %#v", rest)
+			}
+			got := result.Value.(ast.Type)
+			if !got.EqualType(testCase.Wanted) {
+				t.Fatalf(
+					"Wanted:\n%# v\n\nGot:\n%# v\n",
+					pretty.Formatter(testCase.Wanted),
+					pretty.Formatter(got),
+				)
+			}
+
+			canonical := testCase.Canonical
+			if canonical == "" {
+				canonical = testCase.Input
+			}
+			if got.String() != canonical {
+				t.Fatalf("Wanted %#v; got %#v", canonical, got.String())
+			}
+			reparsed := Type(combinator.NewInput(got.String()))
+			if reparsed.Err != nil || reparsed.Rest.String() != "" {
+				t.Fatal("Unexpected error reparsing:", reparsed)
+			}
+			if !reparsed.Value.(ast.Type).EqualType(got) {
+				t.Fatalf(
+					"%v doesn't round-trip; got %v",
+					got,
+					reparsed.Value,
+				)
+			}
+		})
+	}
+}
@@ -8,63 +8,21 @@ import (
 	"github.com/kr/pretty"
 	"github.com/weberc2/gallium/ast"
 	"github.com/weberc2/gallium/combinator"
+	"github.com/weberc2/gallium/diagnostics"
 	"github.com/weberc2/gallium/infer"
 	"github.com/weberc2/gallium/parser"
 )
 
 func main() {
 	scanner := bufio.NewScanner(os.Stdin)
-	env := infer.Environment{
-		"add": ast.FuncSpec{
+	env := infer.Operators()
+	env["add"] = infer.Mono(ast.FuncSpec{
+		ast.Primitive("int"),
+		ast.FuncSpec{
 			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("int"),
-			},
-		},
-		"eq": ast.FuncSpec{
-			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
-		},
-		"ne": ast.FuncSpec{
-			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
-		},
-		"lt": ast.FuncSpec{
-			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
-		},
-		"gt": ast.FuncSpec{
-			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
-		},
-		"le": ast.FuncSpec{
 			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
 		},
-		"ge": ast.FuncSpec{
-			ast.Primitive("int"),
-			ast.FuncSpec{
-				ast.Primitive("int"),
-				ast.Primitive("bool"),
-			},
-		},
-	}
+	})
 
 	for {
 		fmt.Print(" > ")
@@ -72,30 +30,34 @@ func main() {
 			break
 		}
 
+		source := diagnostics.Source{Name: "<stdin>", Text: scanner.Text()}
 		result := combinator.Any(
 			parser.LetDecl,
 			parser.Expr,
-		)(combinator.Input(scanner.Text()))
+		)(combinator.NewInput(source.Text))
 		if result.Err != nil {
-			fmt.Println(result.Err)
+			source.Render(os.Stdout, diagnostics.FromResult(result))
 			continue
 		}
 		switch v := result.Value.(type) {
 		case ast.Expr:
 			expr, err := infer.Infer(env, v)
 			if err != nil {
-				fmt.Println(err)
+				source.Render(os.Stdout, diagnostics.FromError(err))
 				continue
 			}
 			fmt.Println(expr.Type.String())
 			// fmt.Println(expr.Type.RenderGo())
 		case ast.LetDecl:
-			expr, err := infer.Infer(env, v.Binding)
+			// the let is inferred like a file's lets so its annotation (if
+			// any) is resolved and checked
+			file, err := infer.File(env, ast.File{Stmts: []ast.Stmt{v}})
 			if err != nil {
-				fmt.Println(err)
+				source.Render(os.Stdout, diagnostics.FromError(err))
 				continue
 			}
-			env[v.Ident] = expr.Type
+			binding := file.Stmts[0].(ast.LetDecl).Binding
+			env[v.Ident] = infer.Generalize(env, binding.Type)
 		default:
 			panic("NOT AN EXPR OR DECL: " + pretty.Sprint(result.Value))
 		}